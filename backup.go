@@ -0,0 +1,211 @@
+package gosheet
+
+import (
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// backupVersion Version of the archive written by Backup.
+// Restore reads archives of this version or older.
+const backupVersion = 1
+
+// backupArchive Contents of a backup, encoded with gob
+type backupArchive struct {
+	Version int
+	// Title Title of the database without the prefix
+	Title       string
+	CreatedTime time.Time
+	Tables      []backupTable
+}
+
+// backupTable A table in a backup
+type backupTable struct {
+	Name    string
+	Columns []string
+	Types   []string
+	// Constraints JSON of the constraints as in the header, empty if none
+	Constraints string
+	// Rows Unformatted values, each row as long as Columns
+	Rows [][]interface{}
+}
+
+// Backup Writes every table of the database, schemes, constraints and rows, to `w`.
+// Restore it with SheetManager.Restore.
+// Each table is read at once, but writes to other tables may happen while backing up.
+func (db *Database) Backup(w io.Writer) error {
+	return db.BackupContext(context.Background(), w)
+}
+
+// BackupContext Backup with context
+func (db *Database) BackupContext(ctx context.Context, w io.Writer) error {
+	ctx = withOperation(ctx, "Database.Backup")
+	tables, err := db.listTables(ctx)
+	if err != nil {
+		return err
+	}
+
+	archive := backupArchive{
+		Version:     backupVersion,
+		Title:       strings.TrimPrefix(db.Spreadsheet().Properties.Title, db.manager.databaseOptions.Prefix),
+		CreatedTime: db.manager.clock.Now().UTC(),
+		Tables:      make([]backupTable, 0, len(tables)),
+	}
+	for _, table := range tables {
+		backup, err := table.backup(ctx)
+		if err != nil {
+			return err
+		}
+		archive.Tables = append(archive.Tables, *backup)
+	}
+
+	data, err := bytesFromInterface(archive)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// backup Reads the scheme and the rows of the table
+func (table *Table) backup(ctx context.Context) (*backupTable, error) {
+	// no writes between reading the header and the rows
+	lock := table.writeLock()
+	lock.Lock()
+	defer lock.Unlock()
+	if err := table.syncIfStale(ctx, lock); err != nil {
+		return nil, err
+	}
+	scheme := table.header()
+	if scheme == nil {
+		return nil, corruptMetadata(table.Name(), "no metadata")
+	}
+
+	backup := &backupTable{
+		Name:    scheme.Name,
+		Columns: scheme.Columns,
+		Types:   scheme.typeNames,
+		Rows:    make([][]interface{}, scheme.Rows),
+	}
+	if scheme.Constraints != nil {
+		constraints, err := json.Marshal(scheme.Constraints.toMap())
+		if err != nil {
+			return nil, err
+		}
+		backup.Constraints = string(constraints)
+	}
+
+	var values [][]interface{}
+	if scheme.Rows > 0 {
+		// numbers and booleans as stored, not as displayed
+		req := newSpreadsheetValuesRequest(table.manager, table.spreadsheet().SpreadsheetId, scheme.Name)
+		if err := req.updateRange(scheme.Name, tableDataStartRowIndex, 0, tableDataStartRowIndex+scheme.Rows, int64(len(scheme.Columns))); err != nil {
+			return nil, err
+		}
+		req.unformatted()
+		valueRange, err := req.Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+		values = valueRange.Values
+	}
+	// trailing empty cells and rows are not returned
+	for i := range backup.Rows {
+		row := make([]interface{}, len(scheme.Columns))
+		for j := range row {
+			row[j] = ""
+			if i < len(values) && j < len(values[i]) {
+				row[j] = values[i][j]
+			}
+		}
+		backup.Rows[i] = row
+	}
+	return backup, nil
+}
+
+// Restore Creates a new database titled `title` from an archive written by Database.Backup.
+// `options` override the time zone, locale and recalculation interval of the manager as in CreateDatabase.
+// If the database already exists, returns ErrDatabaseExists.
+// If the archive is unreadable, returns ErrInvalidBackup.
+func (m *SheetManager) Restore(r io.Reader, title string, options ...CreateOptions) (*Database, error) {
+	return m.RestoreContext(context.Background(), r, title, options...)
+}
+
+// RestoreContext Restore with context
+func (m *SheetManager) RestoreContext(ctx context.Context, r io.Reader, title string, options ...CreateOptions) (*Database, error) {
+	ctx = withOperation(ctx, "SheetManager.Restore")
+	var archive backupArchive
+	if err := gob.NewDecoder(r).Decode(&archive); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	if archive.Version <= 0 || archive.Version > backupVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidBackup, archive.Version)
+	}
+
+	spreadsheet, err := m.createSpreadsheet(ctx, m.databaseOptions.Prefix+title, m.databaseOptions.override(options...))
+	if err != nil {
+		return nil, err
+	}
+	db := &Database{
+		manager:     m,
+		spreadsheet: spreadsheet,
+	}
+	for i := range archive.Tables {
+		if err := db.restoreTable(ctx, &archive.Tables[i]); err != nil {
+			// do not leave a half restored database
+			return nil, m.discardSpreadsheet(ctx, db.ID(), err)
+		}
+	}
+	if err := m.synchronizeFromGoogle(ctx, db); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// restoreTable Creates the table of `backup` and writes its rows
+func (db *Database) restoreTable(ctx context.Context, backup *backupTable) error {
+	if len(backup.Columns) != len(backup.Types) {
+		return fmt.Errorf("%w: table %s has %d columns and %d types", ErrInvalidBackup, backup.Name, len(backup.Columns), len(backup.Types))
+	}
+	for _, t := range backup.Types {
+		if _, ok := columnKind(t); !ok {
+			return fmt.Errorf("%w: unknown type %q in table %s", ErrInvalidBackup, t, backup.Name)
+		}
+	}
+	var constraints []*Constraint
+	if len(backup.Constraints) > 0 {
+		constraint, err := newConstraintFromString(backup.Constraints)
+		if err != nil {
+			return fmt.Errorf("%w: constraints of table %s: %v", ErrInvalidBackup, backup.Name, err)
+		}
+		constraints = append(constraints, constraint)
+	}
+
+	requests, err := createColumns(backup.Columns, backup.Types, constraints...)
+	if err != nil {
+		return err
+	}
+	table, err := db.addTable(ctx, backup.Name, requests)
+	if err != nil {
+		return err
+	}
+	if len(backup.Rows) == 0 {
+		return nil
+	}
+
+	lock := table.writeLock()
+	lock.Lock()
+	defer lock.Unlock()
+	defer lock.bump()
+	scheme := table.header()
+	req := newSpreadsheetValuesBatchUpdateRequest(table.manager, table.spreadsheet().SpreadsheetId, scheme.Name)
+	if err := req.updateRange(scheme, false, backup.Rows); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	req.updateRows(scheme, false, len(backup.Rows))
+	return req.Do(ctx)
+}
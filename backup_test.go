@@ -0,0 +1,158 @@
+package gosheet
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/helloworldpark/gsheet-db-go/gsheettest"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	clock := newFakeClock()
+	manager, server := newTestManager(t, WithClock(clock))
+	defer server.Close()
+
+	db := newTestDatabase(t, manager)
+	memeTable, err := db.CreateTable(TestStructMeme{}, NewConstraint().SetUniqueColumns("Name5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := memeTable.UpsertIf(memes(4), true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateTable(TestStructSmall{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := db.Backup(&buf); err != nil {
+		t.Fatal(err)
+	}
+	archive := buf.Bytes()
+	var decoded backupArchive
+	if err := gob.NewDecoder(bytes.NewReader(archive)).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.CreatedTime.Equal(clock.Now()) {
+		t.Fatalf("expected backup time %v, got %v", clock.Now(), decoded.CreatedTime)
+	}
+
+	restored, err := manager.Restore(bytes.NewReader(archive), "testdb_restored")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.ID() == db.ID() {
+		t.Fatal("restored over the original")
+	}
+	tables, err := restored.ListTables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 2 || tables[0].Name() != "TestStructMeme" || tables[1].Name() != "TestStructSmall" {
+		t.Fatalf("unexpected restored tables %v", tables)
+	}
+
+	expected, expectedScheme, err := memeTable.Select(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, scheme, err := tables[0].Select(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Fatalf("expected rows %v, restored %v", expected, rows)
+	}
+	if !reflect.DeepEqual(scheme.Types, expectedScheme.Types) || !reflect.DeepEqual(scheme.Constraints, expectedScheme.Constraints) {
+		t.Fatalf("expected scheme %+v, restored %+v", expectedScheme, scheme)
+	}
+	// the unique constraint still holds
+	if err := tables[0].UpsertIf(memes(1), true); err != nil {
+		t.Fatal(err)
+	}
+	if rows, _, err := tables[0].Select(-1); err != nil || len(rows) != 4 {
+		t.Fatalf("expected 4 rows after a duplicate upsert, got %d: %v", len(rows), err)
+	}
+	if rows, _, err := tables[1].Select(-1); err != nil || len(rows) != 0 {
+		t.Fatalf("expected an empty table, got %d: %v", len(rows), err)
+	}
+
+	if _, err := manager.Restore(bytes.NewReader(archive), "testdb_restored"); !errors.Is(err, ErrDatabaseExists) {
+		t.Fatalf("expected ErrDatabaseExists, got %v", err)
+	}
+	if _, err := manager.Restore(bytes.NewReader([]byte("not a backup")), "testdb_invalid"); !errors.Is(err, ErrInvalidBackup) {
+		t.Fatalf("expected ErrInvalidBackup, got %v", err)
+	}
+	future, err := bytesFromInterface(backupArchive{Version: backupVersion + 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.Restore(bytes.NewReader(future), "testdb_invalid"); !errors.Is(err, ErrInvalidBackup) {
+		t.Fatalf("expected ErrInvalidBackup for a newer version, got %v", err)
+	}
+	if _, err := manager.FindDatabase("testdb_invalid"); !errors.Is(err, ErrDatabaseNotFound) {
+		t.Fatalf("invalid backups should not create a database, got %v", err)
+	}
+}
+
+// cancelTransport Cancels the context of the calls once a request path contains `at`
+type cancelTransport struct {
+	base   http.RoundTripper
+	at     string
+	cancel context.CancelFunc
+}
+
+func (t *cancelTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.Contains(req.URL.Path, t.at) {
+		t.cancel()
+	}
+	return t.base.RoundTrip(req)
+}
+
+func TestRestoreCanceled(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
+	if _, err := db.CreateTable(TestStructSmall{}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := db.Backup(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// canceled while reading the header of the restored table
+	transport := &cancelTransport{base: server.Client().Transport, at: "/values/", cancel: cancel}
+	canceling, err := NewSheetManagerFromTokenSource(server.TokenSource(),
+		WithHTTPClient(&http.Client{Transport: transport}),
+		WithSheetsEndpoint(server.SheetsEndpoint()),
+		WithDriveEndpoint(server.DriveEndpoint()),
+		WithRateLimits(RateLimits{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := canceling.RestoreContext(ctx, bytes.NewReader(buf.Bytes()), "testdb_restored"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if _, err := manager.FindDatabase("testdb_restored"); !errors.Is(err, ErrDatabaseNotFound) {
+		t.Fatalf("half restored database left behind: %v", err)
+	}
+
+	// a failed cleanup is reported
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	transport.cancel = cancel
+	server.InjectFault(1, gsheettest.Fault{Status: http.StatusForbidden, Method: http.MethodDelete})
+	_, err = canceling.RestoreContext(ctx, bytes.NewReader(buf.Bytes()), "testdb_restored")
+	if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "not deleted") {
+		t.Fatalf("expected a cleanup failure, got %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package gosheet
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"testing"
@@ -8,7 +9,7 @@ import (
 )
 
 func unitBenchmarkUpsertif(table *Table, values []interface{}, appendData bool) {
-	table.upsertIf(values, appendData)
+	table.upsertIf(context.Background(), values, appendData)
 }
 
 func createRandomDataMeme() ([]interface{}, int) {
@@ -32,92 +33,60 @@ func createRandomDataMeme() ([]interface{}, int) {
 }
 
 func BenchmarkTest(t *testing.B) {
-	analyseStruct(TestStructMeme{})
+	if _, err := analyseStruct(TestStructMeme{}); err != nil {
+		t.Fatal(err)
+	}
 }
 
 func BenchmarkUpsertif(t *testing.B) {
 	// create table
 	t.StopTimer()
-	manager := NewSheetManager(jsonPath)
-	db := manager.FindDatabase("testdb")
-	if db == nil {
-		t.Fatalf("Sheet %s is nil", "testdb")
-	}
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
 
-	// Find or make table
-	table := db.FindTable(TestStructMeme{})
-	if table == nil {
-		table = db.createTable(TestStructMeme{})
-		// fmt.Println("Table created\n", table.Name(), table.sheetID())
-	} else {
-		// fmt.Println("Table found\n", table.Name(), table.sheetID())
+	// make table
+	table, err := db.createTable(context.Background(), TestStructMeme{})
+	if err != nil {
+		t.Fatal(err)
 	}
-	// tableName := table.Name()
-	deleted := table.Drop()
-	if deleted {
-		// fmt.Println("Deleted Table ", tableName)
-	} else {
-		// fmt.Println("Failed drop Table ", tableName)
-	}
-
-	table = db.createTable(TestStructMeme{})
 	// fmt.Println("Table recreated")
 	// describeTable(table)
 
 	// fmt.Println("Starting benchmark")
 	for i := 0; i < 10; i++ {
 		values, _ := createRandomDataMeme()
-		table.manager.enqueueAPIUsage(2, true)
 		t.StartTimer()
-		table.upsertIf(values, true)
+		table.upsertIf(context.Background(), values, true)
 		t.StopTimer()
 	}
-	now := time.Now().In(time.FixedZone("GMT-7", -7*60*60)).Unix()
-	next := ((now / 100) + 1) * 100
-	<-time.NewTimer(time.Duration(next-now+1) * time.Second).C
 	// fmt.Println("Finished benchmark")
 }
 
 func BenchmarkSelect(t *testing.B) {
 	// create table
 	t.StopTimer()
-	manager := NewSheetManager(jsonPath)
-	db := manager.FindDatabase("testdb")
-	if db == nil {
-		t.Fatalf("Sheet %s is nil", "testdb")
-	}
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
 
-	// Find or make table
-	table := db.FindTable(TestStructMeme{})
-	if table == nil {
-		table = db.createTable(TestStructMeme{})
-		// fmt.Println("Table created\n", table.Name(), table.sheetID())
-	} else {
-		// fmt.Println("Table found\n", table.Name(), table.sheetID())
-	}
-	// tableName := table.Name()
-	deleted := table.Drop()
-	if deleted {
-		// fmt.Println("Deleted Table ", tableName)
-	} else {
-		// fmt.Println("Failed drop Table ", tableName)
+	// make table
+	table, err := db.createTable(context.Background(), TestStructMeme{})
+	if err != nil {
+		t.Fatal(err)
 	}
-
-	table = db.createTable(TestStructMeme{})
 	// fmt.Println("Table recreated")
 	// describeTable(table)
 
 	// fmt.Println("Starting benchmark")
 	values, _ := createRandomDataMeme()
-	table.UpsertIf(values, true)
+	if err := table.UpsertIf(values, true); err != nil {
+		t.Fatal(err)
+	}
 	for i := 0; i < 10; i++ {
-		table.manager.enqueueAPIUsage(1, true)
 		t.StartTimer()
-		table.selectData(-1)
+		table.selectData(context.Background(), -1)
 		t.StopTimer()
 	}
-	now := time.Now().In(time.FixedZone("GMT-7", -7*60*60)).Unix()
-	next := ((now / 100) + 1) * 100
-	<-time.NewTimer(time.Duration(next-now+1) * time.Second).C
 	// fmt.Println("Finished benchmark")
 }
@@ -0,0 +1,92 @@
+package gosheet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// Clone copies the database to a new database titled `newTitle`, e.g. to try migrations on staging.
+// Copies only the tables without their rows if `schemaOnly`.
+// The copy goes to the folder of the manager if set, or else next to the database.
+// Returns ErrDatabaseExists if a database titled `newTitle` exists.
+func (db *Database) Clone(newTitle string, schemaOnly bool) (*Database, error) {
+	return db.CloneContext(context.Background(), newTitle, schemaOnly)
+}
+
+// CloneContext Clone with context
+func (db *Database) CloneContext(ctx context.Context, newTitle string, schemaOnly bool) (*Database, error) {
+	ctx = withOperation(ctx, "Database.Clone")
+	m := db.manager
+	title := m.databaseOptions.Prefix + newTitle
+	existing, err := m.findFiles(ctx, title, false, driveFileFields)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrDatabaseExists, newTitle)
+	}
+
+	copiedID, err := m.copySpreadsheet(ctx, db.ID(), title)
+	if err != nil {
+		return nil, err
+	}
+	clone, err := db.cloneFrom(ctx, copiedID, schemaOnly)
+	if err != nil {
+		// do not leave a half copied database
+		return nil, m.discardSpreadsheet(ctx, copiedID, err)
+	}
+	return clone, nil
+}
+
+// cloneFrom opens the copy `copiedID`, removing the rows if `schemaOnly`
+func (db *Database) cloneFrom(ctx context.Context, copiedID string, schemaOnly bool) (*Database, error) {
+	spreadsheet, err := db.manager.getSpreadsheet(ctx, copiedID)
+	if err != nil {
+		return nil, err
+	}
+	clone := &Database{
+		manager:     db.manager,
+		spreadsheet: spreadsheet,
+	}
+	if !schemaOnly {
+		return clone, nil
+	}
+
+	tables, err := clone.listTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range tables {
+		if err := table.truncate(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return clone, nil
+}
+
+// copySpreadsheet copies spreadsheet file `spreadsheetID` as `title` and returns the id of the copy
+func (m *SheetManager) copySpreadsheet(ctx context.Context, spreadsheetID, title string) (string, error) {
+	body := map[string]interface{}{"name": title}
+	if parent := m.parentID(); len(parent) > 0 {
+		body["parents"] = []string{parent}
+	}
+	req, err := newJSONRequest(ctx, m, post, m.driveEndpoint+"files/"+url.PathEscape(spreadsheetID)+"/copy", body)
+	if err != nil {
+		return "", err
+	}
+	req.AddQuery("supportsAllDrives", "true")
+	req.AddQuery("fields", "id")
+	resp, err := req.Do("drive.files.copy")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var copied driveFile
+	if err := json.NewDecoder(resp.Body).Decode(&copied); err != nil {
+		return "", apiError("drive.files.copy", err)
+	}
+	return copied.ID, nil
+}
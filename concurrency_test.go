@@ -0,0 +1,139 @@
+package gosheet
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// Run with -race
+func TestConcurrentReadsAndWrites(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
+	shared := findOrCreateTable(t, db, TestStructMeme{})
+
+	const workers = 8
+	const writes = 2
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*writes*2)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			// half share the table, half have their own handle of it
+			table := shared
+			if w%2 == 1 {
+				var err error
+				if table, err = db.FindTable(TestStructMeme{}); err != nil {
+					errs <- err
+					return
+				}
+			}
+			for i := 0; i < writes; i++ {
+				row := TestStructMeme{Name3: w*writes + i, Name5: fmt.Sprintf("worker%d-%d", w, i)}
+				if err := table.UpsertIf([]interface{}{row}, true); err != nil {
+					errs <- err
+				}
+				if _, _, err := table.Select(-1); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	data, scheme, err := shared.Select(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scheme.Rows != workers*writes || len(data) != workers*writes {
+		t.Fatalf("expected %d rows, got %d (header %d)", workers*writes, len(data), scheme.Rows)
+	}
+	seen := make(map[string]bool)
+	for _, row := range data {
+		seen[row[4].(string)] = true
+	}
+	if len(seen) != workers*writes {
+		t.Fatalf("rows were overwritten, %d distinct rows left", len(seen))
+	}
+}
+
+func TestConcurrentDatabaseAccess(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
+	constraint := NewConstraint()
+	constraint.SetUniqueColumns("Name")
+	table := findOrCreateTable(t, db, TestStructSmall{}, constraint)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 30)
+	for w := 0; w < 6; w++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			if _, err := manager.FindDatabase("testdb"); err != nil {
+				errs <- err
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := db.ListTables(); err != nil {
+				errs <- err
+			}
+		}()
+		go func(w int) {
+			defer wg.Done()
+			// every worker writes the same unique row
+			if err := table.UpsertIf([]interface{}{TestStructSmall{Name: "unique"}}, true); err != nil {
+				errs <- err
+			}
+			_ = db.Spreadsheet().Properties.Title
+			_ = table.header().Rows
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if rows := table.header().Rows; rows != 1 {
+		t.Fatalf("unique row was written %d times", rows)
+	}
+}
+
+func TestConcurrentCreateTable(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
+
+	schemes := []interface{}{TestStructMeme{}, TestStructSmall{}, TestStructNested{}, TestStructTaggedKey{}, TestStructWide{}}
+	var wg sync.WaitGroup
+	errs := make(chan error, len(schemes))
+	for _, scheme := range schemes {
+		wg.Add(1)
+		go func(scheme interface{}) {
+			defer wg.Done()
+			if _, err := db.CreateTable(scheme); err != nil {
+				errs <- err
+			}
+		}(scheme)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	tables, err := db.ListTables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != len(schemes) {
+		t.Fatalf("expected %d tables, got %d", len(schemes), len(tables))
+	}
+}
@@ -2,6 +2,7 @@ package gosheet
 
 import (
 	"encoding/json"
+	"fmt"
 )
 
 // Constraint Describes table constraints. Only unique columns constraint supported.
@@ -17,40 +18,39 @@ func NewConstraint() *Constraint {
 }
 
 // newConstraintFromString Returns pointer to restored constraint from JSON string.
-func newConstraintFromString(str string) *Constraint {
+// Returns nil without error if `str` is empty.
+func newConstraintFromString(str string) (*Constraint, error) {
 	if len(str) == 0 {
-		return nil
+		return nil, nil
 	}
 	constraintMap := make(map[string]interface{})
 	err := json.Unmarshal([]byte(str), &constraintMap)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	constraint := &Constraint{}
 	if v, ok := constraintMap["uniqueColumns"]; ok {
+		columns, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("uniqueColumns is %T, not an array", v)
+		}
 		vstring := make([]string, 0)
-		for _, str := range v.([]interface{}) {
-			vstring = append(vstring, str.(string))
+		for _, column := range columns {
+			columnString, ok := column.(string)
+			if !ok {
+				return nil, fmt.Errorf("unique column %v is not a string", column)
+			}
+			vstring = append(vstring, columnString)
 		}
 		constraint.uniqueColumns = vstring
 	}
-	return constraint
+	return constraint, nil
 }
 
 // SetUniqueColumns Sets unique columns to table
+// Setting the same columns again leaves the constraint unchanged.
 func (c *Constraint) SetUniqueColumns(columns ...string) *Constraint {
-	// Check if valid
-	if len(columns) > 0 && len(columns) == len(c.uniqueColumns) {
-		allSame := true
-		for i := range columns {
-			allSame = allSame && (columns[i] == c.uniqueColumns[i])
-		}
-		if allSame {
-			panic("Unique Constraint shouldn't be equal to before")
-		}
-	}
-
 	c.uniqueColumns = columns
 	return c
 }
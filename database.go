@@ -1,19 +1,28 @@
 package gosheet
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"reflect"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
 	"google.golang.org/api/sheets/v4"
 )
 
+// dbFileStart Default prefix of database titles
 const dbFileStart = "database_file_"
 const tableDataStartRowIndex int64 = 3
 const tableDataStartColumnIndex int64 = 0
@@ -24,134 +33,402 @@ const tableDataStartColumnIndex int64 = 0
 
 // SheetManager Manage OAuth2 token lifecycle
 type SheetManager struct {
-	client  *http.Client
-	service *sheets.Service
+	client        *http.Client
+	service       *sheets.Service
+	driveEndpoint string
 
-	token          *oauth2.Token
-	credentialJSON string
+	// tokenSource is safe for concurrent use
+	tokenSource oauth2.TokenSource
 
-	apiUsage      int64
-	lastQuotaTime int64
+	limiter *rateLimiter
+	meter   *usageMeter
+	clock   Clock
+
+	databaseOptions DatabaseOptions
+
+	mu         sync.Mutex // guards writeLocks
+	writeLocks map[string]*tableWriteLock
 }
 
-// NewSheetManager Create new SheetManager
-// api count: 1
-func NewSheetManager(jsonPath string) *SheetManager {
-	client := http.DefaultClient
-	service, _ := sheets.New(client)
-	token := createJWTToken(jsonPath)
+// NewSheetManager Create new SheetManager from the service account json key file at `jsonPath`
+func NewSheetManager(jsonPath string, opts ...ManagerOption) (*SheetManager, error) {
+	jsonKey, err := ioutil.ReadFile(jsonPath)
+	if err != nil {
+		return nil, err
+	}
+	return NewSheetManagerFromJSON(jsonKey, opts...)
+}
+
+// NewSheetManagerFromJSON Create new SheetManager from service account json key bytes,
+// e.g. loaded from a secret manager or an environment variable
+func NewSheetManagerFromJSON(jsonKey []byte, opts ...ManagerOption) (*SheetManager, error) {
+	cfg := newManagerConfig(opts)
+	tokenSource, err := tokenSourceFromJSON(jsonKey, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newSheetManager(tokenSource, cfg)
+}
+
+// NewSheetManagerWithDefaultCredentials Create new SheetManager from Application Default Credentials
+func NewSheetManagerWithDefaultCredentials(ctx context.Context, opts ...ManagerOption) (*SheetManager, error) {
+	cfg := newManagerConfig(opts)
+	tokenSource, err := defaultTokenSource(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newSheetManager(tokenSource, cfg)
+}
+
+// NewSheetManagerFromTokenSource Create new SheetManager authorizing with tokens from `tokenSource`.
+// Tokens are cached and refreshed only when expired.
+// `tokenSource` already decides the identity and scopes: WithSubject fails and WithScopes is ignored.
+func NewSheetManagerFromTokenSource(tokenSource oauth2.TokenSource, opts ...ManagerOption) (*SheetManager, error) {
+	cfg := newManagerConfig(opts)
+	if len(cfg.subject) > 0 {
+		return nil, errors.New("gosheet: WithSubject needs service account credentials, not a token source")
+	}
+	return newSheetManager(tokenSource, cfg)
+}
+
+func newSheetManager(tokenSource oauth2.TokenSource, cfg *managerConfig) (*SheetManager, error) {
+	limiter := newRateLimiter(cfg.limits, cfg.clock)
+	meter := newUsageMeter()
+	client := apiClient(cfg, limiter, meter)
+	serviceOptions := []option.ClientOption{option.WithHTTPClient(client)}
+	if len(cfg.sheetsEndpoint) > 0 {
+		serviceOptions = append(serviceOptions, option.WithEndpoint(cfg.sheetsEndpoint))
+	}
+	service, err := sheets.NewService(context.Background(), serviceOptions...)
+	if err != nil {
+		return nil, err
+	}
 
 	m := &SheetManager{
-		client:         client,
-		token:          token,
-		service:        service,
-		credentialJSON: jsonPath,
+		client:        client,
+		service:       service,
+		driveEndpoint: cfg.driveEndpoint,
+		tokenSource:   oauth2.ReuseTokenSource(nil, tokenSource),
+		limiter:       limiter,
+		meter:         meter,
+		clock:         cfg.clock,
+
+		databaseOptions: cfg.database,
+	}
+	// fetch the first token to check credentials
+	if _, err := m.tokenSource.Token(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// apiClient Copy of the configured client, rate limiting, metering and retrying every request.
+// A retried request waits for the limiter and is counted again.
+func apiClient(cfg *managerConfig, limiter *rateLimiter, meter *usageMeter) *http.Client {
+	base := cfg.client.Transport
+	if base == nil {
+		base = http.DefaultTransport
 	}
-	m.enqueueAPIUsage(1, false)
-	return m
+	var transport http.RoundTripper = &meterTransport{base: base, meter: meter}
+	transport = &limitTransport{base: transport, limiter: limiter, driveEndpoint: cfg.driveEndpoint}
+	if cfg.retry.MaxAttempts > 1 {
+		transport = &retryTransport{base: transport, policy: cfg.retry, clock: cfg.clock}
+	}
+	client := *cfg.client
+	client.Transport = transport
+	return &client
 }
 
-// refreshToken refreshes token if not valid
-func (m *SheetManager) refreshToken() {
-	if !m.token.Valid() {
-		token := createJWTToken(m.credentialJSON)
-		m.token = token
+// authorize Sets the bearer token to `header`, refreshing the token if not valid
+func (m *SheetManager) authorize(header http.Header) error {
+	token, err := m.tokenSource.Token()
+	if err != nil {
+		return err
 	}
-	return
+	header.Set("Authorization", token.Type()+" "+token.AccessToken)
+	return nil
 }
 
 // CreateDatabase creates a new database with the given database_file_`title`.
-// Be careful, 'database_file_' tag is on the start of the title.
-// api count: 1
-func (m *SheetManager) CreateDatabase(title string) *Database {
-	db, sheetCount := m.createSpreadsheet(dbFileStart + title)
-	m.enqueueAPIUsage(sheetCount+1, false)
+// Be careful, the prefix('database_file_' unless set by WithDatabaseOptions) is on the start of the title.
+// `options` override the time zone, locale and recalculation interval of the manager for this database.
+// If the database already exists, returns ErrDatabaseExists.
+func (m *SheetManager) CreateDatabase(title string, options ...CreateOptions) (*Database, error) {
+	return m.CreateDatabaseContext(context.Background(), title, options...)
+}
+
+// CreateDatabaseContext CreateDatabase with context
+func (m *SheetManager) CreateDatabaseContext(ctx context.Context, title string, options ...CreateOptions) (*Database, error) {
+	ctx = withOperation(ctx, "SheetManager.CreateDatabase")
+	db, err := m.createSpreadsheet(ctx, m.databaseOptions.Prefix+title, m.databaseOptions.override(options...))
+	if err != nil {
+		return nil, err
+	}
 	return &Database{
 		manager:     m,
 		spreadsheet: db,
-	}
+	}, nil
 }
 
 // FindDatabase gets a new database with the given database_file_`title`, if exists.
-// If not existing, it will return nil.
-// Be careful, 'database_file_' tag is on the start of the title finding for.
-// api count: 1
-func (m *SheetManager) FindDatabase(title string) *Database {
-	db, sheetCount := m.findSpreadsheet(dbFileStart + title)
-	m.enqueueAPIUsage(sheetCount+1, false)
-	if db != nil {
-		return &Database{
-			manager:     m,
-			spreadsheet: db,
+// If not existing, it will return ErrDatabaseNotFound.
+// Be careful, the prefix('database_file_' unless set by WithDatabaseOptions) is on the start of the title finding for.
+func (m *SheetManager) FindDatabase(title string) (*Database, error) {
+	return m.FindDatabaseContext(context.Background(), title)
+}
+
+// FindDatabaseContext FindDatabase with context
+func (m *SheetManager) FindDatabaseContext(ctx context.Context, title string) (*Database, error) {
+	ctx = withOperation(ctx, "SheetManager.FindDatabase")
+	db, err := m.findSpreadsheet(ctx, m.databaseOptions.Prefix+title)
+	if err != nil {
+		return nil, err
+	}
+	if db == nil {
+		return nil, fmt.Errorf("%w: %s", ErrDatabaseNotFound, title)
+	}
+	return &Database{
+		manager:     m,
+		spreadsheet: db,
+	}, nil
+}
+
+// OpenDatabase opens the database with the spreadsheet ID `spreadsheetID` with a single call.
+// The spreadsheet ID is also the Drive file ID, e.g. the ID in the spreadsheet's URL or Database.ID.
+// Returns ErrDatabaseNotFound if the spreadsheet does not exist or its title lacks the prefix of database files.
+func (m *SheetManager) OpenDatabase(spreadsheetID string) (*Database, error) {
+	return m.OpenDatabaseContext(context.Background(), spreadsheetID)
+}
+
+// OpenDatabaseContext OpenDatabase with context
+func (m *SheetManager) OpenDatabaseContext(ctx context.Context, spreadsheetID string) (*Database, error) {
+	ctx = withOperation(ctx, "SheetManager.OpenDatabase")
+	db, err := m.getSpreadsheet(ctx, spreadsheetID)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode() == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s: %v", ErrDatabaseNotFound, spreadsheetID, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(db.Properties.Title, m.databaseOptions.Prefix) {
+		return nil, fmt.Errorf("%w: %s is not a database file", ErrDatabaseNotFound, spreadsheetID)
+	}
+	return &Database{
+		manager:     m,
+		spreadsheet: db,
+	}, nil
+}
+
+// DatabaseInfo Metadata of a database file
+type DatabaseInfo struct {
+	// Title Title without the prefix, as given to CreateDatabase
+	Title string
+	// ID Spreadsheet ID, to open with OpenDatabase
+	ID           string
+	CreatedTime  time.Time
+	ModifiedTime time.Time
+	// Owner Email address of the owner. Empty if the file has no owner, e.g. in a shared drive.
+	Owner string
+	// Tables Names of the tables
+	Tables []string
+}
+
+// ListDatabases lists every database file the manager can see, sorted by title.
+// Costs a Drive search per 1000 databases and a call per database for the table names.
+func (m *SheetManager) ListDatabases() ([]*DatabaseInfo, error) {
+	return m.ListDatabasesContext(context.Background())
+}
+
+// ListDatabasesContext ListDatabases with context
+func (m *SheetManager) ListDatabasesContext(ctx context.Context) ([]*DatabaseInfo, error) {
+	ctx = withOperation(ctx, "SheetManager.ListDatabases")
+	files, err := m.listSpreadsheets(ctx, "", false, driveFileInfoFields)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]*DatabaseInfo, 0, len(files))
+	for _, f := range files {
+		spreadsheet, err := m.getSpreadsheet(ctx, f.ID)
+		if err != nil {
+			return nil, err
 		}
+		info := &DatabaseInfo{
+			Title:        strings.TrimPrefix(f.Name, m.databaseOptions.Prefix),
+			ID:           f.ID,
+			CreatedTime:  f.CreatedTime,
+			ModifiedTime: f.ModifiedTime,
+		}
+		if len(f.Owners) > 0 {
+			info.Owner = f.Owners[0].EmailAddress
+		}
+		db := &Database{manager: m, spreadsheet: spreadsheet}
+		for _, sheet := range spreadsheet.Sheets {
+			if db.isValidTable(sheet) {
+				info.Tables = append(info.Tables, sheet.Properties.Title)
+			}
+		}
+		infos = append(infos, info)
 	}
-	return nil
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Title < infos[j].Title
+	})
+	return infos, nil
 }
 
-// DropDatabase deletes a database with the given database_file_`title`, if exists.
-// If not existing, or failed to delete, it will log and return false.
-// Be careful, 'database_file_' tag is implicitly on the start of the title finding for.
-func (m *SheetManager) DropDatabase(title string) bool {
-	db := m.FindDatabase(title)
-	if db == nil {
-		return false
+// DropDatabase moves a database with the given database_file_`title` to the trash of Drive, if exists.
+// Restore it with RestoreDatabase, or delete it permanently with PurgeDatabase.
+// Drive deletes files in the trash after 30 days.
+// If not existing, returns ErrDatabaseNotFound.
+// Be careful, the prefix('database_file_' unless set by WithDatabaseOptions) is implicitly on the start of the title finding for.
+func (m *SheetManager) DropDatabase(title string) error {
+	return m.DropDatabaseContext(context.Background(), title)
+}
+
+// DropDatabaseContext DropDatabase with context
+func (m *SheetManager) DropDatabaseContext(ctx context.Context, title string) error {
+	ctx = withOperation(ctx, "SheetManager.DropDatabase")
+	files, err := m.findFiles(ctx, m.databaseOptions.Prefix+title, false, driveFileFields)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("%w: %s", ErrDatabaseNotFound, title)
 	}
-	m.enqueueAPIUsage(1, false)
-	return m.deleteSpreadsheet(db.spreadsheet.SpreadsheetId)
+	return m.trashSpreadsheet(ctx, files[0].ID, true)
+}
+
+// RestoreDatabase takes a database dropped by DropDatabase out of the trash.
+// Restores the latest modified one if several are in the trash.
+// Returns ErrDatabaseNotFound if none is in the trash,
+// and ErrDatabaseExists if a database with the same title exists.
+func (m *SheetManager) RestoreDatabase(title string) (*Database, error) {
+	return m.RestoreDatabaseContext(context.Background(), title)
+}
+
+// RestoreDatabaseContext RestoreDatabase with context
+func (m *SheetManager) RestoreDatabaseContext(ctx context.Context, title string) (*Database, error) {
+	ctx = withOperation(ctx, "SheetManager.RestoreDatabase")
+	fullTitle := m.databaseOptions.Prefix + title
+	live, err := m.findFiles(ctx, fullTitle, false, driveFileFields)
+	if err != nil {
+		return nil, err
+	}
+	if len(live) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrDatabaseExists, title)
+	}
+	trashed, err := m.findFiles(ctx, fullTitle, true, "id,name,modifiedTime")
+	if err != nil {
+		return nil, err
+	}
+	if len(trashed) == 0 {
+		return nil, fmt.Errorf("%w: %s in the trash", ErrDatabaseNotFound, title)
+	}
+	latest := trashed[0]
+	for _, f := range trashed[1:] {
+		if f.ModifiedTime.After(latest.ModifiedTime) {
+			latest = f
+		}
+	}
+
+	if err := m.trashSpreadsheet(ctx, latest.ID, false); err != nil {
+		return nil, err
+	}
+	db, err := m.getSpreadsheet(ctx, latest.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &Database{
+		manager:     m,
+		spreadsheet: db,
+	}, nil
+}
+
+// PurgeDatabase permanently deletes databases with the given database_file_`title` in the trash.
+// Drop a database first to purge it. Returns ErrDatabaseNotFound if none is in the trash.
+func (m *SheetManager) PurgeDatabase(title string) error {
+	return m.PurgeDatabaseContext(context.Background(), title)
+}
+
+// PurgeDatabaseContext PurgeDatabase with context
+func (m *SheetManager) PurgeDatabaseContext(ctx context.Context, title string) error {
+	ctx = withOperation(ctx, "SheetManager.PurgeDatabase")
+	trashed, err := m.findFiles(ctx, m.databaseOptions.Prefix+title, true, driveFileFields)
+	if err != nil {
+		return err
+	}
+	if len(trashed) == 0 {
+		return fmt.Errorf("%w: %s in the trash", ErrDatabaseNotFound, title)
+	}
+	for _, f := range trashed {
+		if err := m.deleteSpreadsheet(ctx, f.ID); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // synchronizeFromGoogle Synchronize data from google
-// api count: 1
-func (m *SheetManager) synchronizeFromGoogle(db *Database) *Database {
+func (m *SheetManager) synchronizeFromGoogle(ctx context.Context, db *Database) error {
 	if db == nil {
 		return nil
 	}
 
-	rawDB := m.getSpreadsheet(db.spreadsheet.SpreadsheetId)
-	if rawDB == nil {
-		fmt.Printf("[SheetManager] SyncDatabaseToGoogle: DB is nil")
-		return nil
+	rawDB, err := m.getSpreadsheet(ctx, db.Spreadsheet().SpreadsheetId)
+	if err != nil {
+		return err
 	}
+	db.mu.Lock()
 	db.spreadsheet = rawDB
-	return db
+	db.mu.Unlock()
+	return nil
 }
 
 /*
- * google sheets api queue
+ * table write locks
  */
 
-func (m *SheetManager) enqueueAPIUsage(task int64, blockIfQuota bool) {
-	// 출처: https://hakurei.tistory.com/193 [Reimu's Development Blog])
-	now := time.Now().In(time.FixedZone("GMT-7", -7*60*60)).Unix()
-	if m.lastQuotaTime == 0 {
-		m.lastQuotaTime = (now / 100) * 100
-	} else if m.lastQuotaTime+100 <= now {
-		m.lastQuotaTime = (now / 100) * 100
-	}
-	// check if api usage enough
-	// fmt.Println("API Usage: ", m.apiUsage)
-	if m.apiUsage >= 90 {
-		if blockIfQuota {
-			timeToWait := time.Second * time.Duration(m.lastQuotaTime+100-now)
-			// fmt.Printf("[%v] Pending %v seconds, api usage: %v\n", now, m.lastQuotaTime+100-now, m.apiUsage)
-			<-time.NewTimer(timeToWait).C
-		}
+// tableWriteLock Serializes writes to a table from every *Table of the same manager
+type tableWriteLock struct {
+	version int64 // bumped after every write, accessed atomically
+	sync.Mutex
+}
 
-		now = time.Now().In(time.FixedZone("GMT-7", -7*60*60)).Unix()
-		// fmt.Printf("[%v] Resetting quota\n", now)
-		m.lastQuotaTime += 100
-		m.apiUsage = 0
+// writeLock Lock of the table `sheetID` in spreadsheet `spreadsheetID`
+func (m *SheetManager) writeLock(spreadsheetID string, sheetID int64) *tableWriteLock {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.writeLocks == nil {
+		m.writeLocks = make(map[string]*tableWriteLock)
+	}
+	key := fmt.Sprintf("%s/%d", spreadsheetID, sheetID)
+	lock, ok := m.writeLocks[key]
+	if !ok {
+		lock = &tableWriteLock{}
+		m.writeLocks[key] = lock
 	}
-	m.apiUsage += task
+	return lock
+}
+
+func (l *tableWriteLock) currentVersion() int64 {
+	return atomic.LoadInt64(&l.version)
+}
+
+func (l *tableWriteLock) bump() {
+	atomic.AddInt64(&l.version, 1)
 }
 
 /*
  * Database api
  */
 
-// Database Wrapper for *sheets.Spreadsheet
+// Database Wrapper for *sheets.Spreadsheet.
+// Safe for concurrent use.
 type Database struct {
-	manager     *SheetManager
+	manager *SheetManager
+
+	mu          sync.RWMutex // guards spreadsheet
 	spreadsheet *sheets.Spreadsheet
 }
 
@@ -161,13 +438,22 @@ func (m *Database) Manager() *SheetManager {
 }
 
 // Spreadsheet Proxy
+// The returned spreadsheet is a snapshot, do not modify it.
 func (m *Database) Spreadsheet() *sheets.Spreadsheet {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	return m.spreadsheet
 }
 
+// ID Spreadsheet ID of the database, which is also its Drive file ID.
+// Keep it to open the database later with OpenDatabase.
+func (m *Database) ID() string {
+	return m.Spreadsheet().SpreadsheetId
+}
+
 // Sheets Proxy
 func (m *Database) Sheets() []*sheets.Sheet {
-	return m.spreadsheet.Sheets
+	return m.Spreadsheet().Sheets
 }
 
 func (m *Database) isValidTable(sheet *sheets.Sheet) bool {
@@ -178,157 +464,312 @@ func (m *Database) isValidTable(sheet *sheets.Sheet) bool {
 }
 
 // newTableFromSheet creates new *Table instance
-// api count: 2
-func (m *Database) newTableFromSheet(sheet *sheets.Sheet) *Table {
-	req := newSpreadsheetValuesRequest(m.manager, m.Spreadsheet().SpreadsheetId, sheet.Properties.Title)
-	req.updateRange(sheet.Properties.Title, 0, 0, 3, 25) // todo: hardcoding
-	valueRange := req.Do()
-
-	if valueRange == nil {
-		return nil
+func (m *Database) newTableFromSheet(ctx context.Context, sheet *sheets.Sheet) (*Table, error) {
+	// header rows span every column of the grid
+	columns := int64(26)
+	if grid := sheet.Properties.GridProperties; grid != nil && grid.ColumnCount > 0 {
+		columns = grid.ColumnCount
 	}
-
-	// todo: error check
-	rows, err := strconv.ParseInt(valueRange.Values[2][0].(string), 10, 64)
-	if err != nil {
-		panic(err)
+	req := newSpreadsheetValuesRequest(m.manager, m.Spreadsheet().SpreadsheetId, sheet.Properties.Title)
+	if err := req.updateRange(sheet.Properties.Title, 0, 0, 3, columns); err != nil {
+		return nil, err
 	}
-	cols, err := strconv.ParseInt(valueRange.Values[2][1].(string), 10, 64)
+	valueRange, err := req.Do(ctx)
 	if err != nil {
-		panic(err)
-	}
-	constraint := ""
-	if len(valueRange.Values[2]) > 2 {
-		constraint = valueRange.Values[2][2].(string)
+		return nil, err
 	}
 
-	colnames := make([]string, cols)
-	dtypes := make([]reflect.Kind, cols)
-	for i := range colnames {
-		colnames[i] = valueRange.Values[0][i].(string)
-		dtypes[i] = primitiveStringToKind[valueRange.Values[1][i].(string)]
+	scheme, err := parseTableScheme(sheet.Properties.Title, valueRange.Values)
+	if err != nil {
+		return nil, err
 	}
 
 	table := &Table{}
 	table.sheet = sheet
 	table.database = m
 	table.manager = m.manager
-	table.scheme = &TableScheme{}
-	table.scheme.Columns = colnames
-
-	table.scheme.Name = sheet.Properties.Title
-	table.scheme.Rows = rows
-	table.scheme.Types = dtypes
+	table.scheme = scheme
 
-	table.scheme.Constraints = newConstraintFromString(constraint)
 	// update index
 	// will update if constraints is valid
-	table.updatedHeader()
-	table.updateIndex()
-	return table
+	if err := table.sync(ctx); err != nil {
+		return nil, err
+	}
+	return table, nil
 }
 
 /*
  * Database api implementations
  */
 
-// createSpreadsheet creates a single spreadsheet file
-// api count: 1 + sheetIDs
-func (m *SheetManager) createSpreadsheet(title string) (*sheets.Spreadsheet, int64) {
+// createSpreadsheet creates a single spreadsheet file with the properties of `options`
+func (m *SheetManager) createSpreadsheet(ctx context.Context, title string, options DatabaseOptions) (*sheets.Spreadsheet, error) {
 	// check duplicated title
-	db, sheetCount := m.findSpreadsheet(title)
+	db, err := m.findSpreadsheet(ctx, title)
+	if err != nil {
+		return nil, err
+	}
 	if db != nil {
-		return nil, sheetCount
+		return nil, fmt.Errorf("%w: %s", ErrDatabaseExists, title)
 	}
 
-	return newSpreadsheetCreateRequest(m, title).Do(), sheetCount
+	if parent := m.parentID(); len(parent) > 0 {
+		return m.createSpreadsheetIn(ctx, parent, title, options)
+	}
+	return newSpreadsheetCreateRequest(m, title, options).Do(ctx)
+}
+
+// parentID Folder or shared drive for new databases. Empty for the root of My Drive.
+func (m *SheetManager) parentID() string {
+	if len(m.databaseOptions.FolderID) > 0 {
+		return m.databaseOptions.FolderID
+	}
+	return m.databaseOptions.SharedDriveID
+}
+
+// createSpreadsheetIn creates a spreadsheet in `parent` through Drive, since Sheets creates files only in the root.
+// Sets the properties of `options` after, deleting the file if failed.
+func (m *SheetManager) createSpreadsheetIn(ctx context.Context, parent, title string, options DatabaseOptions) (*sheets.Spreadsheet, error) {
+	req, err := newJSONRequest(ctx, m, post, m.driveEndpoint+"files", map[string]interface{}{
+		"name":     title,
+		"mimeType": spreadsheetMimeType,
+		"parents":  []string{parent},
+	})
+	if err != nil {
+		return nil, err
+	}
+	req.AddQuery("supportsAllDrives", "true")
+	req.AddQuery("fields", "id")
+	resp, err := req.Do("drive.files.create")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var created driveFile
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return nil, apiError("drive.files.create", err)
+	}
+
+	fields := "timeZone,autoRecalc"
+	if len(options.Locale) > 0 {
+		fields += ",locale"
+	}
+	update := &sheets.Request{
+		UpdateSpreadsheetProperties: &sheets.UpdateSpreadsheetPropertiesRequest{
+			Properties: &sheets.SpreadsheetProperties{
+				TimeZone:   options.TimeZone,
+				Locale:     options.Locale,
+				AutoRecalc: string(options.AutoRecalc),
+			},
+			Fields: fields,
+		},
+	}
+	spreadsheet, _, err := newSpreadsheetBatchUpdateRequest(m, created.ID, update).Do(ctx)
+	if err != nil {
+		return nil, m.discardSpreadsheet(ctx, created.ID, err)
+	}
+	return spreadsheet, nil
 }
 
-// getSpreadsheet gets a single spreadsheet file with id, if exists.
-// api count: 1
-func (m *SheetManager) getSpreadsheet(spreadsheetID string) *sheets.Spreadsheet {
-	req := m.service.Spreadsheets.Get(spreadsheetID).IncludeGridData(true)
-	req.Header().Add("Authorization", "Bearer "+m.token.AccessToken)
+// spreadsheetMetadataFields Field mask of spreadsheets without cells.
+// Tables read their cells through the values API, so the payload does not grow with the tables.
+const spreadsheetMetadataFields = "spreadsheetId,spreadsheetUrl,properties,sheets.properties"
+
+// getSpreadsheet gets the metadata of a single spreadsheet file with id, if exists.
+func (m *SheetManager) getSpreadsheet(ctx context.Context, spreadsheetID string) (*sheets.Spreadsheet, error) {
+	req := m.service.Spreadsheets.Get(spreadsheetID).Fields(spreadsheetMetadataFields).Context(ctx)
+	if err := m.authorize(req.Header()); err != nil {
+		return nil, err
+	}
 	resp, err := req.Do()
 	if err != nil {
-		panic(err)
+		return nil, apiError("spreadsheets.get", err)
+	}
+	return resp, nil
+}
+
+// discardSpreadsheet Deletes the incomplete spreadsheet `spreadsheetID` left by a call with `ctx` failing with `err`.
+// Deletes it even if `ctx` is done, counted to the same operation, and adds a failure of deleting it to `err`.
+func (m *SheetManager) discardSpreadsheet(ctx context.Context, spreadsheetID string, err error) error {
+	cleanup := context.Background()
+	if operation, ok := ctx.Value(operationKey{}).(string); ok {
+		cleanup = withOperation(cleanup, operation)
 	}
-	return resp
+	if deleteErr := m.deleteSpreadsheet(cleanup, spreadsheetID); deleteErr != nil {
+		return fmt.Errorf("%w (incomplete database %s not deleted: %v)", err, spreadsheetID, deleteErr)
+	}
+	return err
 }
 
 // deleteSpreadsheet deletes spreadsheet file with `spreadsheetId`
-// Returns true if deleted(status code 20X)
-//         false if else
+// Returns nil if deleted(status code 20X)
 // https://stackoverflow.com/questions/46836393/how-do-i-delete-a-spreadsheet-file-using-google-spreadsheets-api
 // https://stackoverflow.com/questions/46310113/consume-a-delete-endpoint-from-golang
-// api count: 1
-func (m *SheetManager) deleteSpreadsheet(spreadsheetID string) bool {
-	resp := newURLRequest(m, delete, fmt.Sprintf("https://www.googleapis.com/drive/v3/files/%s", spreadsheetID)).Do()
-	return resp.StatusCode/100 == 2
-}
-
-// listSpreadsheets lists spreadsheets' id by []string
-// api count: 1
-func (m *SheetManager) listSpreadsheets() []string {
-	req := newURLRequest(m, get, "https://www.googleapis.com/drive/v3/files")
-	// https://stackoverflow.com/questions/30652577/go-doing-a-get-request-and-building-the-querystring
-	// https://developers.google.com/drive/api/v3/mime-types
-	req.AddQuery("q", "mimeType='application/vnd.google-apps.spreadsheet'")
-	resp := req.Do()
-	read, err := ioutil.ReadAll(resp.Body)
-	resp.Body.Close()
+func (m *SheetManager) deleteSpreadsheet(ctx context.Context, spreadsheetID string) error {
+	req, err := newURLRequest(ctx, m, delete, m.driveEndpoint+"files/"+url.PathEscape(spreadsheetID))
 	if err != nil {
-		panic(err)
+		return err
 	}
+	req.AddQuery("supportsAllDrives", "true")
+	resp, err := req.Do("drive.files.delete")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
 
-	var readJSON interface{}
-	json.Unmarshal(read, &readJSON)
+const spreadsheetMimeType = "application/vnd.google-apps.spreadsheet"
 
-	mapJSON, ok := readJSON.(map[string]interface{})
-	if !ok {
-		return nil
+// driveListPageSize Files requested per page of drive.files.list, at most 1000
+var driveListPageSize = 1000
+
+// Fields of files requested from drive.files.list
+const (
+	driveFileFields     = "id,name"
+	driveFileInfoFields = "id,name,createdTime,modifiedTime,owners(emailAddress)"
+)
+
+// driveFile File resource of drive.files.list, only with the requested fields
+type driveFile struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	CreatedTime  time.Time `json:"createdTime"`
+	ModifiedTime time.Time `json:"modifiedTime"`
+	Owners       []struct {
+		EmailAddress string `json:"emailAddress"`
+	} `json:"owners"`
+}
+
+// trashSpreadsheet moves spreadsheet file `spreadsheetID` to the trash, or out of it
+func (m *SheetManager) trashSpreadsheet(ctx context.Context, spreadsheetID string, trashed bool) error {
+	// setting the same value twice is harmless
+	ctx = withIdempotentWrite(ctx)
+	req, err := newJSONRequest(ctx, m, patch, m.driveEndpoint+"files/"+url.PathEscape(spreadsheetID), map[string]interface{}{
+		"trashed": trashed,
+	})
+	if err != nil {
+		return err
 	}
+	req.AddQuery("supportsAllDrives", "true")
+	req.AddQuery("fields", "id")
+	resp, err := req.Do("drive.files.update")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
 
-	files, ok := mapJSON["files"]
-	if !ok {
-		return nil
+// driveFileList response of drive.files.list
+type driveFileList struct {
+	NextPageToken string      `json:"nextPageToken"`
+	Files         []driveFile `json:"files"`
+}
+
+// driveQueryString Quotes `value` as a string literal of Drive search queries
+// https://developers.google.com/drive/api/v3/ref-search-terms
+func driveQueryString(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+// spreadsheetQuery Drive query for database files in the trash or not, in folder `folderID` if not empty,
+// titled `title` if not empty, or else starting with `prefix`
+func spreadsheetQuery(folderID, prefix, title string, trashed bool) string {
+	q := fmt.Sprintf("mimeType = %s and trashed = %t", driveQueryString(spreadsheetMimeType), trashed)
+	if len(folderID) > 0 {
+		q += fmt.Sprintf(" and %s in parents", driveQueryString(folderID))
 	}
-	fileArr, ok := files.([]interface{})
-	if !ok {
-		return nil
+	if len(title) > 0 {
+		return q + " and name = " + driveQueryString(title)
 	}
+	// contains matches prefixes of names
+	return q + " and name contains " + driveQueryString(prefix)
+}
 
-	var sheetArr []string
-	for _, f := range fileArr {
-		fmap, ok := f.(map[string]interface{})
-		if !ok {
-			continue
+// listSpreadsheets lists database files titled `title`, or every database file if `title` is empty,
+// in the trash or not. Follows every page of the results. Files have only `fields`.
+func (m *SheetManager) listSpreadsheets(ctx context.Context, title string, trashed bool, fields string) ([]driveFile, error) {
+	var files []driveFile
+	pageToken := ""
+	for {
+		req, err := newURLRequest(ctx, m, get, m.driveEndpoint+"files")
+		if err != nil {
+			return nil, err
+		}
+		// https://stackoverflow.com/questions/30652577/go-doing-a-get-request-and-building-the-querystring
+		// https://developers.google.com/drive/api/v3/mime-types
+		options := m.databaseOptions
+		req.AddQuery("q", spreadsheetQuery(options.FolderID, options.Prefix, title, trashed))
+		// https://developers.google.com/drive/api/v3/enable-shareddrives
+		req.AddQuery("supportsAllDrives", "true")
+		req.AddQuery("includeItemsFromAllDrives", "true")
+		if len(options.SharedDriveID) > 0 {
+			req.AddQuery("corpora", "drive")
+			req.AddQuery("driveId", options.SharedDriveID)
 		}
-		trashed, ok := fmap["trashed"].(bool)
-		if ok && trashed {
-			continue
+		req.AddQuery("fields", "nextPageToken,files("+fields+")")
+		req.AddQuery("pageSize", strconv.Itoa(driveListPageSize))
+		if len(pageToken) > 0 {
+			req.AddQuery("pageToken", pageToken)
 		}
-		fileID, ok := fmap["id"].(string)
-		if !ok {
-			continue
+		fileList, err := decodeFileList(req)
+		if err != nil {
+			return nil, err
 		}
-		sheetArr = append(sheetArr, fileID)
 
+		for _, f := range fileList.Files {
+			// `contains` also matches words in the middle of names
+			if strings.HasPrefix(f.Name, m.databaseOptions.Prefix) {
+				files = append(files, f)
+			}
+		}
+		if len(fileList.NextPageToken) == 0 {
+			return files, nil
+		}
+		pageToken = fileList.NextPageToken
 	}
-	return sheetArr
 }
 
-// findSpreadsheet finds a spreadsheet with `title`
-// api count: 1 + sheetIDs
-func (m *SheetManager) findSpreadsheet(title string) (*sheets.Spreadsheet, int64) {
-	sheetIDs := m.listSpreadsheets()
-	for _, sheetID := range sheetIDs {
-		s := m.getSpreadsheet(sheetID)
-		if s.Properties.Title == title {
-			return s, int64(len(sheetIDs))
+func decodeFileList(req *httpURLRequest) (*driveFileList, error) {
+	resp, err := req.Do("drive.files.list")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var fileList driveFileList
+	if err := json.NewDecoder(resp.Body).Decode(&fileList); err != nil {
+		return nil, apiError("drive.files.list", err)
+	}
+	return &fileList, nil
+}
+
+// findFiles lists database files titled exactly `title`, in the trash or not
+func (m *SheetManager) findFiles(ctx context.Context, title string, trashed bool, fields string) ([]driveFile, error) {
+	files, err := m.listSpreadsheets(ctx, title, trashed, fields)
+	if err != nil {
+		return nil, err
+	}
+	var found []driveFile
+	for _, f := range files {
+		if f.Name == title {
+			found = append(found, f)
 		}
 	}
-	return nil, 0
+	return found, nil
+}
+
+// findSpreadsheet finds a spreadsheet with `title`, searching by title on Drive
+// Returns nil without error if not found
+func (m *SheetManager) findSpreadsheet(ctx context.Context, title string) (*sheets.Spreadsheet, error) {
+	files, err := m.findFiles(ctx, title, false, driveFileFields)
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
+	return m.getSpreadsheet(ctx, files[0].ID)
 }
 
 /*
@@ -342,6 +783,7 @@ const (
 	post   httpMethod = "POST"
 	delete httpMethod = "DELETE"
 	put    httpMethod = "PUT"
+	patch  httpMethod = "PATCH"
 )
 
 type httpURLRequest struct {
@@ -349,15 +791,33 @@ type httpURLRequest struct {
 	manager *SheetManager
 }
 
-func newURLRequest(manager *SheetManager, method httpMethod, url string) *httpURLRequest {
-	req, err := http.NewRequest(string(method), url, nil)
+func newURLRequest(ctx context.Context, manager *SheetManager, method httpMethod, url string) (*httpURLRequest, error) {
+	req, err := http.NewRequestWithContext(ctx, string(method), url, nil)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	return &httpURLRequest{
 		req:     req,
 		manager: manager,
+	}, nil
+}
+
+// newJSONRequest Request sending `body` in JSON
+func newJSONRequest(ctx context.Context, manager *SheetManager, method httpMethod, url string, body interface{}) (*httpURLRequest, error) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	// bytes.Reader lets the request be retried
+	req, err := http.NewRequestWithContext(ctx, string(method), url, bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
 	}
+	req.Header.Set("Content-Type", "application/json")
+	return &httpURLRequest{
+		req:     req,
+		manager: manager,
+	}, nil
 }
 
 func (r *httpURLRequest) AddQuery(key, value string) *httpURLRequest {
@@ -367,14 +827,20 @@ func (r *httpURLRequest) AddQuery(key, value string) *httpURLRequest {
 	return r
 }
 
-func (r *httpURLRequest) Do() *http.Response {
-	r.manager.refreshToken()
-	r.req.Header.Add("Authorization", "Bearer "+r.manager.token.AccessToken)
+// Do Sends the request. Responses other than 20X are returned as *APIError named `op`.
+func (r *httpURLRequest) Do(op string) (*http.Response, error) {
+	if err := r.manager.authorize(r.req.Header); err != nil {
+		return nil, err
+	}
 	resp, err := r.manager.client.Do(r.req)
 	if err != nil {
-		panic(err)
+		return nil, apiError(op, err)
+	}
+	if err := googleapi.CheckResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, apiError(op, err)
 	}
-	return resp
+	return resp, nil
 }
 
 type httpSpreadsheetCreateRequest struct {
@@ -382,12 +848,13 @@ type httpSpreadsheetCreateRequest struct {
 	manager *SheetManager
 }
 
-func newSpreadsheetCreateRequest(manager *SheetManager, title string) *httpSpreadsheetCreateRequest {
+func newSpreadsheetCreateRequest(manager *SheetManager, title string, options DatabaseOptions) *httpSpreadsheetCreateRequest {
 	rb := &sheets.Spreadsheet{
 		Properties: &sheets.SpreadsheetProperties{
 			Title:      title,
-			TimeZone:   "Asia/Seoul",
-			AutoRecalc: "ON_CHANGE",
+			TimeZone:   options.TimeZone,
+			Locale:     options.Locale,
+			AutoRecalc: string(options.AutoRecalc),
 		},
 	}
 	req := manager.service.Spreadsheets.Create(rb)
@@ -402,14 +869,15 @@ func (r *httpSpreadsheetCreateRequest) Header() http.Header {
 	return r.req.Header()
 }
 
-func (r *httpSpreadsheetCreateRequest) Do() *sheets.Spreadsheet {
-	r.manager.refreshToken()
-	r.req.Header().Add("Authorization", "Bearer "+r.manager.token.AccessToken)
-	resp, err := r.req.Do()
+func (r *httpSpreadsheetCreateRequest) Do(ctx context.Context) (*sheets.Spreadsheet, error) {
+	if err := r.manager.authorize(r.req.Header()); err != nil {
+		return nil, err
+	}
+	resp, err := r.req.Context(ctx).Do()
 	if err != nil {
-		panic(err)
+		return nil, apiError("spreadsheets.create", err)
 	}
-	return resp
+	return resp, nil
 }
 
 type httpBatchUpdateRequest struct {
@@ -436,21 +904,26 @@ func (r *httpBatchUpdateRequest) updateRequest(req *sheets.Request) {
 	r.batchRequest.Requests = append(r.batchRequest.Requests, req)
 }
 
-func (r *httpBatchUpdateRequest) Do() (*sheets.Spreadsheet, []*sheets.Response, int) {
-	r.manager.refreshToken()
-	req := r.manager.service.Spreadsheets.BatchUpdate(r.spreadsheetID, r.batchRequest)
-	req.Header().Add("Authorization", "Bearer "+r.manager.token.AccessToken)
+func (r *httpBatchUpdateRequest) Do(ctx context.Context) (*sheets.Spreadsheet, []*sheets.Response, error) {
+	req := r.manager.service.Spreadsheets.BatchUpdate(r.spreadsheetID, r.batchRequest).
+		Fields("replies", "updatedSpreadsheet("+spreadsheetMetadataFields+")").
+		Context(ctx)
+	if err := r.manager.authorize(req.Header()); err != nil {
+		return nil, nil, err
+	}
 	resp, err := req.Do()
 	if err != nil {
-		panic(err)
+		return nil, nil, apiError("spreadsheets.batchUpdate", err)
 	}
-	return resp.UpdatedSpreadsheet, resp.Replies, resp.HTTPStatusCode
+	return resp.UpdatedSpreadsheet, resp.Replies, nil
 }
 
 type httpValueRangeRequest struct {
 	manager       *SheetManager
 	ranges        string
 	spreadsheetID string
+	// valueRenderOption FORMATTED_VALUE if empty
+	valueRenderOption string
 }
 
 func newSpreadsheetValuesRequest(manager *SheetManager, spreadsheetID, tableName string) *httpValueRangeRequest {
@@ -462,22 +935,33 @@ func newSpreadsheetValuesRequest(manager *SheetManager, spreadsheetID, tableName
 }
 
 // updateRange does not include end
-func (r *httpValueRangeRequest) updateRange(tablename string, startRow, startCol, endRow, endCol int64) bool {
-	ranges := newCellRange(tablename, startRow, startCol, endRow, endCol)
-	r.manager.refreshToken()
+func (r *httpValueRangeRequest) updateRange(tablename string, startRow, startCol, endRow, endCol int64) error {
+	ranges, err := newCellRange(tablename, startRow, startCol, endRow, endCol)
+	if err != nil {
+		return err
+	}
 	r.ranges = ranges.String()
-	return true
+	return nil
+}
+
+// unformatted reads numbers and booleans as they are, not as displayed
+func (r *httpValueRangeRequest) unformatted() {
+	r.valueRenderOption = "UNFORMATTED_VALUE"
 }
 
-func (r *httpValueRangeRequest) Do() *sheets.ValueRange {
-	r.manager.refreshToken()
-	req := r.manager.service.Spreadsheets.Values.Get(r.spreadsheetID, r.ranges)
-	req.Header().Add("Authorization", "Bearer "+r.manager.token.AccessToken)
+func (r *httpValueRangeRequest) Do(ctx context.Context) (*sheets.ValueRange, error) {
+	req := r.manager.service.Spreadsheets.Values.Get(r.spreadsheetID, r.ranges).Context(ctx)
+	if len(r.valueRenderOption) > 0 {
+		req.ValueRenderOption(r.valueRenderOption)
+	}
+	if err := r.manager.authorize(req.Header()); err != nil {
+		return nil, err
+	}
 	valueRange, err := req.Do()
 	if err != nil {
-		panic(err)
+		return nil, apiError("spreadsheets.values.get", err)
 	}
-	return valueRange
+	return valueRange, nil
 }
 
 type spreadsheetValuesBatchUpdateRequest struct {
@@ -498,24 +982,26 @@ func newSpreadsheetValuesBatchUpdateRequest(manager *SheetManager, spreadsheetID
 }
 
 // rangeString does not include end
-func rangeString(metadata *TableScheme, startRow, appendingRow int64) cellRange {
+func rangeString(metadata *TableScheme, startRow, appendingRow int64) (cellRange, error) {
 	endRow := startRow + appendingRow
 	const startCol = tableDataStartColumnIndex
 	endCol := startCol + int64(len(metadata.Columns))
 
-	ranges := newCellRange(metadata.Name, startRow, startCol, endRow, endCol)
-	return ranges
+	return newCellRange(metadata.Name, startRow, startCol, endRow, endCol)
 }
 
 // updateRange does not include end
 // values: values[0]: 0th struct, values[0][4]: 4th column value of 0th struct
-func (r *spreadsheetValuesBatchUpdateRequest) updateRange(scheme *TableScheme, appendData bool, values [][]interface{}) bool {
+func (r *spreadsheetValuesBatchUpdateRequest) updateRange(scheme *TableScheme, appendData bool, values [][]interface{}) error {
 	if len(values) == 0 {
-		return false
+		return nil
 	}
 
 	r.updatingValues = nil
 	for i := range values {
+		if len(values[i]) < len(scheme.Columns) {
+			return fmt.Errorf("%w: row %d has %d columns, table %s has %d", ErrSchemaMismatch, i, len(values[i]), scheme.Name, len(scheme.Columns))
+		}
 		r.updatingValues = append(r.updatingValues, make([]interface{}, 0))
 		for j := 0; j < len(scheme.Columns); j++ {
 			r.updatingValues[i] = append(r.updatingValues[i], values[i][j])
@@ -526,9 +1012,12 @@ func (r *spreadsheetValuesBatchUpdateRequest) updateRange(scheme *TableScheme, a
 	if appendData {
 		startRow += scheme.Rows
 	}
-	ranges := rangeString(scheme, startRow, int64(len(values)))
+	ranges, err := rangeString(scheme, startRow, int64(len(values)))
+	if err != nil {
+		return err
+	}
 	r.rangeValues = ranges.String()
-	return true
+	return nil
 }
 
 func (r *spreadsheetValuesBatchUpdateRequest) updateRows(scheme *TableScheme, appendData bool, newRows int) bool {
@@ -544,9 +1033,7 @@ func (r *spreadsheetValuesBatchUpdateRequest) updateRows(scheme *TableScheme, ap
 	return true
 }
 
-func (r *spreadsheetValuesBatchUpdateRequest) Do() int {
-	r.manager.refreshToken()
-
+func (r *spreadsheetValuesBatchUpdateRequest) Do(ctx context.Context) error {
 	batchRequest := &sheets.BatchUpdateValuesRequest{}
 	batchRequest.IncludeValuesInResponse = true
 	batchRequest.ValueInputOption = "RAW"
@@ -573,20 +1060,15 @@ func (r *spreadsheetValuesBatchUpdateRequest) Do() int {
 		batchRequest.Data[1] = rangeRows
 	}
 
-	req := r.manager.service.Spreadsheets.Values.BatchUpdate(r.spreadsheetID, batchRequest)
-	req.Header().Add("Authorization", "Bearer "+r.manager.token.AccessToken)
-	updatedRange, err := req.Do()
-
-	if err != nil {
-		// gerr, ok := err.(*googleapi.Error)
-		// if ok {
-		// 	for _, em := range gerr.Errors {
-		// 		fmt.Printf("-ErrorItem Message: %v Reason: %v\n", em.Message, em.Reason)
-		// 	}
-		// }
-		panic(err)
+	// writing the same values to the same ranges twice is harmless
+	req := r.manager.service.Spreadsheets.Values.BatchUpdate(r.spreadsheetID, batchRequest).Context(withIdempotentWrite(ctx))
+	if err := r.manager.authorize(req.Header()); err != nil {
+		return err
+	}
+	if _, err := req.Do(); err != nil {
+		return apiError("spreadsheets.values.batchUpdate", err)
 	}
-	return updatedRange.HTTPStatusCode
+	return nil
 }
 
 type clearValuesRequest struct {
@@ -603,15 +1085,14 @@ func newClearValuesRequest(manager *SheetManager, spreadsheetID string, ranges c
 	}
 }
 
-func (r *clearValuesRequest) Do() int {
-	r.manager.refreshToken()
+func (r *clearValuesRequest) Do(ctx context.Context) error {
 	clearRequest := &sheets.ClearValuesRequest{}
-	req := r.manager.service.Spreadsheets.Values.Clear(r.spreadsheetID, r.ranges.String(), clearRequest)
-	req.Header().Add("Authorization", "Bearer "+r.manager.token.AccessToken)
-	resp, err := req.Do()
-	if err != nil {
-		fmt.Println(err.Error())
-		return 400
+	req := r.manager.service.Spreadsheets.Values.Clear(r.spreadsheetID, r.ranges.String(), clearRequest).Context(withIdempotentWrite(ctx))
+	if err := r.manager.authorize(req.Header()); err != nil {
+		return err
+	}
+	if _, err := req.Do(); err != nil {
+		return apiError("spreadsheets.values.clear", err)
 	}
-	return resp.HTTPStatusCode
+	return nil
 }
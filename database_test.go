@@ -1,18 +1,351 @@
 package gosheet
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"testing"
-	"time"
+
+	"github.com/helloworldpark/gsheet-db-go/gsheettest"
+	"golang.org/x/oauth2"
 )
 
-func TestMiniServer(t *testing.T) {
-	manager := NewSheetManager(jsonPath)
-	for i := 0; i < 100; i++ {
-		rand.Seed(time.Now().Unix())
-		usage := rand.Int63n(5) + 1
-		fmt.Println(i, "Usage: ", usage)
-		manager.enqueueAPIUsage(usage, true)
+// newTestManager SheetManager talking to a fake server. Close the server when done.
+func newTestManager(t testing.TB, opts ...ManagerOption) (*SheetManager, *gsheettest.Server) {
+	server := gsheettest.NewServer()
+	opts = append([]ManagerOption{
+		WithHTTPClient(server.Client()),
+		WithSheetsEndpoint(server.SheetsEndpoint()),
+		WithDriveEndpoint(server.DriveEndpoint()),
+		WithRateLimits(RateLimits{}),
+	}, opts...)
+	manager, err := NewSheetManagerFromTokenSource(server.TokenSource(), opts...)
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	return manager, server
+}
+
+type recordingTransport struct {
+	paths []string
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.paths = append(t.paths, req.URL.Path)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestCustomClientAndEndpoints(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer static" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v4/spreadsheets/abc":
+			fmt.Fprint(w, `{"spreadsheetId":"abc","properties":{"title":"database_file_local"}}`)
+		case "/drive/v3/files":
+			fmt.Fprint(w, `{"files":[{"id":"abc","name":"database_file_local"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	transport := &recordingTransport{}
+	manager, err := NewSheetManagerFromTokenSource(
+		oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "static"}),
+		WithHTTPClient(&http.Client{Transport: transport}),
+		WithSheetsEndpoint(server.URL),
+		WithDriveEndpoint(server.URL+"/drive/v3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := manager.FindDatabase("local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Spreadsheet().SpreadsheetId != "abc" {
+		t.Fatalf("unexpected spreadsheet %s", db.Spreadsheet().SpreadsheetId)
+	}
+	if len(transport.paths) != 2 || transport.paths[0] != "/drive/v3/files" || transport.paths[1] != "/v4/spreadsheets/abc" {
+		t.Fatalf("requests did not go through the custom client: %v", transport.paths)
+	}
+}
+
+func TestDatabaseOptions(t *testing.T) {
+	manager, server := newTestManager(t, WithDatabaseOptions(DatabaseOptions{
+		Prefix:     "team_a_",
+		TimeZone:   "America/New_York",
+		Locale:     "fr_FR",
+		AutoRecalc: RecalcHour,
+	}))
+	defer server.Close()
+	defaults, err := NewSheetManagerFromTokenSource(server.TokenSource(),
+		WithHTTPClient(server.Client()),
+		WithSheetsEndpoint(server.SheetsEndpoint()),
+		WithDriveEndpoint(server.DriveEndpoint()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := manager.CreateDatabase("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	properties := db.Spreadsheet().Properties
+	if properties.Title != "team_a_users" || properties.TimeZone != "America/New_York" ||
+		properties.Locale != "fr_FR" || properties.AutoRecalc != "HOUR" {
+		t.Fatalf("unexpected properties %+v", properties)
+	}
+	if _, err := defaults.CreateDatabase("users"); err != nil {
+		t.Fatal(err)
+	}
+
+	// another time zone from the same manager, keeping its prefix
+	paris, err := manager.CreateDatabase("users_paris", CreateOptions{TimeZone: "Europe/Paris"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	properties = paris.Spreadsheet().Properties
+	if properties.Title != "team_a_users_paris" || properties.TimeZone != "Europe/Paris" ||
+		properties.Locale != "fr_FR" || properties.AutoRecalc != "HOUR" {
+		t.Fatalf("unexpected properties %+v", properties)
+	}
+	properties = db.Spreadsheet().Properties
+
+	found, err := manager.FindDatabase("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.ID() != db.ID() {
+		t.Fatalf("found %s instead of %s", found.Spreadsheet().Properties.Title, properties.Title)
+	}
+	if _, err := defaults.OpenDatabase(db.ID()); !errors.Is(err, ErrDatabaseNotFound) {
+		t.Fatalf("expected ErrDatabaseNotFound for another prefix, got %v", err)
+	}
+	if err := manager.DropDatabase("users"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.FindDatabase("users"); !errors.Is(err, ErrDatabaseNotFound) {
+		t.Fatalf("expected ErrDatabaseNotFound, got %v", err)
+	}
+	if _, err := defaults.FindDatabase("users"); err != nil {
+		t.Fatalf("database of the default prefix should remain: %v", err)
+	}
+
+	// empty fields fall back to the defaults
+	partial := newManagerConfig([]ManagerOption{WithDatabaseOptions(DatabaseOptions{Locale: "ko_KR"})})
+	expected := DefaultDatabaseOptions()
+	expected.Locale = "ko_KR"
+	if partial.database != expected {
+		t.Fatalf("unexpected options %+v", partial.database)
+	}
+}
+
+func TestFolderAndSharedDrive(t *testing.T) {
+	server := gsheettest.NewServer()
+	defer server.Close()
+	folderID := server.AddFolder("databases", gsheettest.RootFolderID)
+	driveID := server.AddSharedDrive("team")
+	newManager := func(options DatabaseOptions) *SheetManager {
+		manager, err := NewSheetManagerFromTokenSource(server.TokenSource(),
+			WithHTTPClient(server.Client()),
+			WithSheetsEndpoint(server.SheetsEndpoint()),
+			WithDriveEndpoint(server.DriveEndpoint()),
+			WithRateLimits(RateLimits{}),
+			WithDatabaseOptions(options))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return manager
+	}
+	root := newManager(DatabaseOptions{})
+	folder := newManager(DatabaseOptions{FolderID: folderID, Locale: "de_DE"})
+	shared := newManager(DatabaseOptions{SharedDriveID: driveID})
+
+	if _, err := root.CreateDatabase("in root"); err != nil {
+		t.Fatal(err)
+	}
+	db, err := folder.CreateDatabase("in folder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	properties := db.Spreadsheet().Properties
+	if properties.TimeZone != "Asia/Seoul" || properties.Locale != "de_DE" || len(db.Sheets()) != 1 {
+		t.Fatalf("options not applied to %+v", properties)
+	}
+	if _, err := shared.CreateDatabase("in shared drive"); err != nil {
+		t.Fatal(err)
+	}
+	tokyo, err := folder.CreateDatabase("tokyo in folder", CreateOptions{TimeZone: "Asia/Tokyo", Locale: "ja_JP"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	properties = tokyo.Spreadsheet().Properties
+	if properties.TimeZone != "Asia/Tokyo" || properties.Locale != "ja_JP" {
+		t.Fatalf("overrides not applied to %+v", properties)
+	}
+	if err := folder.DropDatabase("tokyo in folder"); err != nil {
+		t.Fatal(err)
+	}
+
+	titles := func(manager *SheetManager) (titles []string) {
+		infos, err := manager.ListDatabases()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, info := range infos {
+			titles = append(titles, info.Title)
+		}
+		return titles
+	}
+	if list := titles(folder); !reflect.DeepEqual(list, []string{"in folder"}) {
+		t.Fatalf("unexpected databases in folder %v", list)
+	}
+	if list := titles(shared); !reflect.DeepEqual(list, []string{"in shared drive"}) {
+		t.Fatalf("unexpected databases in shared drive %v", list)
+	}
+	if list := titles(root); len(list) != 3 {
+		t.Fatalf("expected databases of every drive, got %v", list)
+	}
+	if _, err := folder.FindDatabase("in root"); !errors.Is(err, ErrDatabaseNotFound) {
+		t.Fatalf("expected ErrDatabaseNotFound outside of the folder, got %v", err)
+	}
+
+	// the same title may exist in another folder
+	if _, err := folder.CreateDatabase("in root"); err != nil {
+		t.Fatal(err)
+	}
+	if err := shared.DropDatabase("in shared drive"); err != nil {
+		t.Fatal(err)
+	}
+	if list := titles(shared); len(list) != 0 {
+		t.Fatalf("database not dropped from shared drive: %v", list)
+	}
+}
+
+func TestDropRestorePurgeDatabase(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+
+	db := newTestDatabase(t, manager)
+	table, err := db.CreateTable(TestStructMeme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.UpsertIf(memes(3), true); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.DropDatabase("testdb"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.FindDatabase("testdb"); !errors.Is(err, ErrDatabaseNotFound) {
+		t.Fatalf("expected ErrDatabaseNotFound after drop, got %v", err)
+	}
+	if err := manager.DropDatabase("testdb"); !errors.Is(err, ErrDatabaseNotFound) {
+		t.Fatalf("expected ErrDatabaseNotFound dropping twice, got %v", err)
+	}
+
+	restored, err := manager.RestoreDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.ID() != db.ID() {
+		t.Fatalf("restored %s instead of %s", restored.ID(), db.ID())
+	}
+	table, err = restored.FindTable(TestStructMeme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows, _, err := table.Select(-1); err != nil || len(rows) != 3 {
+		t.Fatalf("expected 3 rows after restore, got %d: %v", len(rows), err)
+	}
+	if _, err := manager.RestoreDatabase("testdb"); !errors.Is(err, ErrDatabaseExists) {
+		t.Fatalf("expected ErrDatabaseExists, got %v", err)
+	}
+
+	if err := manager.PurgeDatabase("testdb"); !errors.Is(err, ErrDatabaseNotFound) {
+		t.Fatalf("live databases should not be purged, got %v", err)
+	}
+	if err := manager.DropDatabase("testdb"); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.PurgeDatabase("testdb"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.RestoreDatabase("testdb"); !errors.Is(err, ErrDatabaseNotFound) {
+		t.Fatalf("expected ErrDatabaseNotFound after purge, got %v", err)
+	}
+	if _, err := manager.getSpreadsheet(context.Background(), db.ID()); err == nil {
+		t.Fatal("purged spreadsheet still exists")
+	}
+}
+
+func TestCloneDatabase(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+
+	db := newTestDatabase(t, manager)
+	table, err := db.CreateTable(TestStructMeme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.UpsertIf(memes(3), true); err != nil {
+		t.Fatal(err)
+	}
+
+	clone, err := db.Clone("testdb_staging", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clone.ID() == db.ID() || clone.Spreadsheet().Properties.Title != dbFileStart+"testdb_staging" {
+		t.Fatalf("unexpected clone %s %s", clone.ID(), clone.Spreadsheet().Properties.Title)
+	}
+	cloned, err := clone.FindTable(TestStructMeme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows, _, err := cloned.Select(-1); err != nil || len(rows) != 3 {
+		t.Fatalf("expected 3 cloned rows, got %d: %v", len(rows), err)
+	}
+	if _, err := db.Clone("testdb_staging", false); !errors.Is(err, ErrDatabaseExists) {
+		t.Fatalf("expected ErrDatabaseExists, got %v", err)
+	}
+
+	empty, err := db.Clone("testdb_schema", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emptyTable, err := empty.FindTable(TestStructMeme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows, _, err := emptyTable.Select(-1); err != nil || len(rows) != 0 {
+		t.Fatalf("expected no rows in the schema only clone, got %d: %v", len(rows), err)
+	}
+	if !reflect.DeepEqual(emptyTable.header().Columns, table.header().Columns) {
+		t.Fatalf("expected columns %v, got %v", table.header().Columns, emptyTable.header().Columns)
+	}
+	// cells are cleared, not only the row count
+	req := newSpreadsheetValuesRequest(manager, empty.ID(), emptyTable.Name())
+	req.updateRange(emptyTable.Name(), tableDataStartRowIndex, 0, tableDataStartRowIndex+10, int64(len(table.header().Columns)))
+	values, err := req.Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values.Values) != 0 {
+		t.Fatalf("expected cleared cells, got %v", values.Values)
+	}
+
+	// the original is untouched
+	if rows, _, err := table.Select(-1); err != nil || len(rows) != 3 {
+		t.Fatalf("expected 3 rows in the original, got %d: %v", len(rows), err)
 	}
 }
@@ -0,0 +1,155 @@
+package gosheet
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Type names of time columns in the metadata row
+const (
+	timeTypeName     = "time"
+	durationTypeName = "duration"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// timeLayout Layout of time cells, written in UTC and read in the timezone of the database.
+// Stored as text, so Sheets keeps the nanoseconds.
+const timeLayout = time.RFC3339Nano
+
+// columnType Type name in the metadata row and kind of a field typed `t`
+func columnType(t reflect.Type) (string, reflect.Kind, bool) {
+	switch t {
+	case timeType:
+		return timeTypeName, reflect.Struct, true
+	case durationType:
+		return durationTypeName, reflect.Int64, true
+	}
+	if isJSONKind(t.Kind()) {
+		return jsonTypeName, reflect.Interface, true
+	}
+	if t.Kind() == reflect.Ptr {
+		return nullableColumnType(t)
+	}
+	name, ok := primitiveKindToString[t.Kind()]
+	return name, t.Kind(), ok
+}
+
+// location Timezone of the database, UTC if unknown
+func (db *Database) location() *time.Location {
+	spreadsheet := db.Spreadsheet()
+	if spreadsheet == nil || spreadsheet.Properties == nil {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(spreadsheet.Properties.TimeZone)
+	if err != nil {
+		// no tz database on the machine
+		return time.UTC
+	}
+	return loc
+}
+
+// encodeCell Value written to a cell for `value`. nil is NULL, a blank cell, and times are written in UTC.
+func encodeCell(value interface{}) (interface{}, error) {
+	if value == nil {
+		return "", nil
+	}
+	if refl := reflect.ValueOf(value); refl.Kind() == reflect.Ptr {
+		if refl.IsNil() {
+			return "", nil
+		}
+		value = refl.Elem().Interface()
+	}
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC().Format(timeLayout), nil
+	case time.Duration:
+		return v.String(), nil
+	}
+	if value != nil && isJSONKind(reflect.TypeOf(value).Kind()) {
+		return marshalCell(value)
+	}
+	return value, nil
+}
+
+// decodeCell Value of a cell of a column typed `typeName`. JSON is decoded as by json.Unmarshal into interface{}.
+// Empty cells are nil in nullable columns and zero values in time and duration columns, and cells not written by gosheet are returned as they are.
+func decodeCell(value interface{}, typeName string, loc *time.Location) interface{} {
+	text, ok := value.(string)
+	if !ok {
+		return value
+	}
+	if isNullable(typeName) {
+		if len(text) == 0 {
+			return nil
+		}
+		typeName = strings.TrimPrefix(typeName, nullablePrefix)
+	}
+	switch typeName {
+	case timeTypeName:
+		if len(text) == 0 {
+			return time.Time{}
+		}
+		if t, err := time.Parse(timeLayout, text); err == nil {
+			if t.IsZero() {
+				return time.Time{}
+			}
+			return t.In(loc)
+		}
+	case durationTypeName:
+		if len(text) == 0 {
+			return time.Duration(0)
+		}
+		if d, err := time.ParseDuration(text); err == nil {
+			return d
+		}
+	case jsonTypeName:
+		if len(text) == 0 {
+			return nil
+		}
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(text), &decoded); err == nil {
+			return decoded
+		}
+	}
+	return value
+}
+
+// decodeRows Copy of `rows` with time, duration and JSON cells decoded.
+// Rows of tables with nullable columns are as long as the columns, missing cells decoded as blank ones.
+func (table *Table) decodeRows(rows [][]interface{}, scheme *TableScheme) [][]interface{} {
+	decoding := false
+	for _, typeName := range scheme.typeNames {
+		decoding = decoding || isNullable(typeName) || typeName == timeTypeName || typeName == durationTypeName || typeName == jsonTypeName
+	}
+	if !decoding {
+		return rows
+	}
+
+	loc := table.database.location()
+	padding := scheme.hasNullable()
+	decoded := make([][]interface{}, len(rows))
+	for i := range rows {
+		n := len(rows[i])
+		if padding && n < len(scheme.Columns) {
+			n = len(scheme.Columns)
+		}
+		decoded[i] = make([]interface{}, n)
+		for j := range decoded[i] {
+			var cell interface{} = ""
+			if j < len(rows[i]) {
+				cell = rows[i][j]
+			}
+			decoded[i][j] = cell
+			if j < len(scheme.typeNames) {
+				decoded[i][j] = decodeCell(cell, scheme.typeNames[j], loc)
+			}
+		}
+	}
+	return decoded
+}
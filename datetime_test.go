@@ -0,0 +1,123 @@
+package gosheet
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type TestStructEvent struct {
+	Name     string
+	At       time.Time
+	Duration time.Duration
+	Until    time.Time `gsheet:",omitempty"`
+}
+
+func TestTimeColumns(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
+
+	table, err := db.CreateTable(TestStructEvent{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheme := table.header()
+	if !reflect.DeepEqual(scheme.typeNames, []string{"string", "time", "duration", "time"}) {
+		t.Fatalf("unexpected types %v", scheme.typeNames)
+	}
+	if !reflect.DeepEqual(scheme.Types, []reflect.Kind{reflect.String, reflect.Struct, reflect.Int64, reflect.Struct}) {
+		t.Fatalf("unexpected kinds %v", scheme.Types)
+	}
+
+	at := time.Date(2024, 3, 1, 9, 30, 0, 123456789, time.UTC)
+	events := []interface{}{
+		TestStructEvent{Name: "launch", At: at, Duration: 90 * time.Minute, Until: at.Add(time.Hour)},
+		[]interface{}{"review", at.Add(24 * time.Hour), 15 * time.Second, ""},
+		TestStructEvent{Name: "zero"},
+	}
+	if err := table.UpsertIf(events, true); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, _, err := table.Select(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc := db.location()
+	if loc.String() != "Asia/Seoul" {
+		t.Fatalf("expected the timezone of the database, got %s", loc)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows, got %v", rows)
+	}
+	first, ok := rows[0][1].(time.Time)
+	if !ok || !first.Equal(at) || first.Location().String() != loc.String() {
+		t.Fatalf("expected %v in %s, got %#v", at, loc, rows[0][1])
+	}
+	if rows[0][2] != 90*time.Minute || rows[1][2] != 15*time.Second {
+		t.Fatalf("unexpected durations %v %v", rows[0][2], rows[1][2])
+	}
+	if until, ok := rows[0][3].(time.Time); !ok || !until.Equal(at.Add(time.Hour)) {
+		t.Fatalf("unexpected until %#v", rows[0][3])
+	}
+	if rows[2][1] != (time.Time{}) {
+		t.Fatalf("expected zero time, got %#v", rows[2][1])
+	}
+	var selected []TestStructEvent
+	if err := table.SelectInto(&selected); err != nil {
+		t.Fatal(err)
+	}
+	if len(selected) != 3 || selected[2] != (TestStructEvent{Name: "zero"}) {
+		t.Fatalf("unexpected zero event %+v", selected)
+	}
+
+	// stored as text in UTC
+	req := newSpreadsheetValuesRequest(manager, db.ID(), table.Name())
+	req.updateRange(table.Name(), tableDataStartRowIndex, 1, tableDataStartRowIndex+1, 3)
+	raw, err := req.Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(raw.Values, [][]interface{}{{"2024-03-01T09:30:00.123456789Z", "1h30m0s"}}) {
+		t.Fatalf("unexpected cells %v", raw.Values)
+	}
+
+	// predicates see decoded values
+	filtered, _, err := table.SelectAndFilter(map[int]Predicate{
+		1: func(v interface{}) bool { return v.(time.Time).After(at) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filtered) != 1 || filtered[0][0] != "review" {
+		t.Fatalf("unexpected filtered rows %v", filtered)
+	}
+	deleted, err := table.Delete(func(row []interface{}) bool {
+		return row[2].(time.Duration) < time.Minute
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(deleted, []int64{1, 2}) {
+		t.Fatalf("unexpected deleted rows %v", deleted)
+	}
+}
+
+func TestDecodeCell(t *testing.T) {
+	loc := time.FixedZone("KST", 9*60*60)
+	if v := decodeCell("", timeTypeName, loc); v != (time.Time{}) {
+		t.Fatalf("empty time cell is %#v", v)
+	}
+	if v := decodeCell("", durationTypeName, loc); v != time.Duration(0) {
+		t.Fatalf("empty duration cell is %#v", v)
+	}
+	// written by hand
+	if v := decodeCell("2024. 3. 1", timeTypeName, loc); v != "2024. 3. 1" {
+		t.Fatalf("unparsable cell is %#v", v)
+	}
+	if v := decodeCell(1.5, durationTypeName, loc); v != 1.5 {
+		t.Fatalf("number cell is %#v", v)
+	}
+}
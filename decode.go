@@ -0,0 +1,184 @@
+package gosheet
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// SelectInto Selects all the rows into `dest`, a pointer to a slice of structs or of pointers to structs.
+// Columns are matched to fields by the names CreateTable gives them,
+// so nested structs are rebuilt from their flattened columns, e.g. Address.City.
+// Columns without a field are ignored and fields without a column are left zero, as are empty cells.
+// Returns ErrSchemaMismatch if a cell does not fit its field.
+func (table *Table) SelectInto(dest interface{}) error {
+	return table.SelectIntoContext(context.Background(), dest)
+}
+
+// SelectIntoContext SelectInto with context
+func (table *Table) SelectIntoContext(ctx context.Context, dest interface{}) error {
+	ctx = withOperation(ctx, "Table.SelectInto")
+	destValue := reflect.ValueOf(dest)
+	if destValue.Kind() != reflect.Ptr || destValue.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("%w: %T is not a pointer to a slice", ErrUnsupportedType, dest)
+	}
+	slice := destValue.Elem()
+	elemType := slice.Type().Elem()
+	structType := elemType
+	if elemType.Kind() == reflect.Ptr {
+		structType = elemType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return fmt.Errorf("%w: %s is not a struct", ErrUnsupportedType, structType)
+	}
+	fields, err := columnsOf(structType)
+	if err != nil {
+		return err
+	}
+
+	if err := table.syncIfStale(ctx, table.writeLock()); err != nil {
+		return err
+	}
+	data, metadata, err := table.selectRows(ctx, -1, true)
+	if err != nil {
+		return err
+	}
+	loc := table.database.location()
+
+	result := reflect.MakeSlice(slice.Type(), 0, len(data))
+	for i, row := range data {
+		elem := reflect.New(structType)
+		for _, field := range fields {
+			column, ok := metadata.ColumnMap[field.cname]
+			if !ok || column >= int64(len(row)) {
+				continue
+			}
+			if _, ok := fieldByIndex(elem.Elem(), field.index); !ok && row[column] == "" {
+				// blank cells keep the embedded pointer nil
+				continue
+			}
+			fieldValue := allocFieldByIndex(elem.Elem(), field.index)
+			typeName := metadata.typeNames[column]
+			if typeName == jsonTypeName {
+				err = unmarshalCell(fieldValue, row[column])
+			} else {
+				err = setCell(fieldValue, decodeCell(row[column], typeName, loc))
+			}
+			if err != nil {
+				return fmt.Errorf("%w: row %d, column %s: %v", ErrSchemaMismatch, i, field.cname, err)
+			}
+		}
+		if elemType.Kind() == reflect.Ptr {
+			result = reflect.Append(result, elem)
+		} else {
+			result = reflect.Append(result, elem.Elem())
+		}
+	}
+	slice.Set(result)
+	return nil
+}
+
+// allocFieldByIndex reflect.Value.FieldByIndex, allocating nil embedded pointers on the way
+func allocFieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// setCell Sets `v` to an unformatted, decoded cell value. Empty cells leave `v` zero, nil for pointers.
+func setCell(v reflect.Value, cell interface{}) error {
+	if text, ok := cell.(string); cell == nil || ok && len(text) == 0 {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		// NULL is left nil
+		elem := reflect.New(v.Type().Elem())
+		if err := setCell(elem.Elem(), cell); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	case reflect.Bool:
+		switch c := cell.(type) {
+		case bool:
+			v.SetBool(c)
+			return nil
+		case string:
+			if b, err := strconv.ParseBool(c); err == nil {
+				v.SetBool(b)
+				return nil
+			}
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch c := cell.(type) {
+		case float64:
+			if c == math.Trunc(c) && !v.OverflowInt(int64(c)) {
+				v.SetInt(int64(c))
+				return nil
+			}
+		case time.Duration:
+			v.SetInt(int64(c))
+			return nil
+		case string:
+			if n, err := strconv.ParseInt(c, 10, v.Type().Bits()); err == nil {
+				v.SetInt(n)
+				return nil
+			}
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch c := cell.(type) {
+		case float64:
+			if c >= 0 && c == math.Trunc(c) && !v.OverflowUint(uint64(c)) {
+				v.SetUint(uint64(c))
+				return nil
+			}
+		case string:
+			if n, err := strconv.ParseUint(c, 10, v.Type().Bits()); err == nil {
+				v.SetUint(n)
+				return nil
+			}
+		}
+	case reflect.Float32, reflect.Float64:
+		switch c := cell.(type) {
+		case float64:
+			if !v.OverflowFloat(c) {
+				v.SetFloat(c)
+				return nil
+			}
+		case string:
+			if f, err := strconv.ParseFloat(c, v.Type().Bits()); err == nil {
+				v.SetFloat(f)
+				return nil
+			}
+		}
+	case reflect.String:
+		switch c := cell.(type) {
+		case string:
+			v.SetString(c)
+		case float64:
+			v.SetString(strconv.FormatFloat(c, 'f', -1, 64))
+		case bool:
+			v.SetString(trueOrFalse[c])
+		default:
+			v.SetString(fmt.Sprint(c))
+		}
+		return nil
+	case reflect.Struct:
+		if t, ok := cell.(time.Time); ok && v.Type() == timeType {
+			v.Set(reflect.ValueOf(t))
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot decode %v(%T) into %s", cell, cell, v.Type())
+}
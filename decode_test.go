@@ -0,0 +1,226 @@
+package gosheet
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSelectInto(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
+
+	table, err := db.CreateTable(TestStructNested{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedColumns := []string{"ID", "CreatedBy", "Name", "Home.City", "Home.Zip", "work.City", "work.Zip", "Created"}
+	if !reflect.DeepEqual(table.header().Columns, expectedColumns) {
+		t.Fatalf("expected columns %v, got %v", expectedColumns, table.header().Columns)
+	}
+
+	created := time.Date(2024, 3, 1, 9, 0, 0, 0, db.location())
+	expected := []TestStructNested{
+		{
+			TestBase:  TestBase{ID: 1},
+			testAudit: testAudit{CreatedBy: "admin"},
+			Name:      "kim",
+			Home:      TestAddress{City: "Seoul", Zip: 4524},
+			Office:    TestAddress{City: "Pangyo", Zip: 13487},
+			Created:   created,
+		},
+		{
+			TestBase: TestBase{ID: 2},
+			Name:     "lee",
+			Home:     TestAddress{City: "Busan"},
+			Created:  created.Add(time.Hour),
+		},
+	}
+	if err := table.UpsertIf([]interface{}{expected[0], expected[1]}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	var selected []TestStructNested
+	if err := table.SelectInto(&selected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(selected, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, selected)
+	}
+	var pointers []*TestStructNested
+	if err := table.SelectInto(&pointers); err != nil {
+		t.Fatal(err)
+	}
+	if len(pointers) != 2 || !reflect.DeepEqual(*pointers[1], expected[1]) {
+		t.Fatalf("unexpected rows %+v", pointers)
+	}
+
+	// a subset of the columns
+	var homes []struct {
+		Name string
+		Home TestAddress
+	}
+	if err := table.SelectInto(&homes); err != nil {
+		t.Fatal(err)
+	}
+	if len(homes) != 2 || homes[1].Name != "lee" || homes[1].Home.City != "Busan" {
+		t.Fatalf("unexpected rows %+v", homes)
+	}
+
+	var mismatch []struct {
+		Home struct {
+			City int
+		}
+	}
+	if err := table.SelectInto(&mismatch); !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("expected ErrSchemaMismatch, got %v", err)
+	}
+	if err := table.SelectInto(selected); !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("expected ErrUnsupportedType, got %v", err)
+	}
+}
+
+type TestStructEmbeddedPointer struct {
+	*TestAddress
+	Name string
+}
+
+func TestSelectIntoEmbeddedPointer(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
+
+	table, err := db.CreateTable(TestStructEmbeddedPointer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(table.header().Columns, []string{"City", "Zip", "Name"}) {
+		t.Fatalf("unexpected columns %v", table.header().Columns)
+	}
+
+	expected := []TestStructEmbeddedPointer{
+		{TestAddress: &TestAddress{City: "Seoul", Zip: 4524}, Name: "kim"},
+		// nil embedded pointer is blank cells
+		{Name: "lee"},
+	}
+	if err := table.UpsertIf([]interface{}{expected[0], expected[1]}, true); err != nil {
+		t.Fatal(err)
+	}
+	rows, _, err := table.Select(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rows, [][]interface{}{{"Seoul", "4524", "kim"}, {"", "", "lee"}}) {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+
+	var selected []TestStructEmbeddedPointer
+	if err := table.SelectInto(&selected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(selected, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, selected)
+	}
+}
+
+type TestStructRecursive struct {
+	*TestStructRecursive
+	X int
+}
+
+type TestStructCycleA struct {
+	*TestStructCycleB
+	A string
+}
+
+type TestStructCycleB struct {
+	*TestStructCycleA
+	B string
+}
+
+func TestSelectIntoRecursiveEmbedding(t *testing.T) {
+	// a struct on the embedding path is not promoted again
+	fields, err := columnsOf(reflect.TypeOf(TestStructCycleA{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 2 || fields[0].cname != "B" || fields[1].cname != "A" {
+		t.Fatalf("unexpected columns %+v", fields)
+	}
+
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
+
+	table, err := db.CreateTable(TestStructRecursive{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(table.header().Columns, []string{"X"}) {
+		t.Fatalf("unexpected columns %v", table.header().Columns)
+	}
+	if err := table.UpsertIf([]interface{}{TestStructRecursive{X: 1}}, true); err != nil {
+		t.Fatal(err)
+	}
+	var selected []TestStructRecursive
+	if err := table.SelectInto(&selected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(selected, []TestStructRecursive{{X: 1}}) {
+		t.Fatalf("unexpected rows %+v", selected)
+	}
+}
+
+type TestStructTenColumns struct {
+	A, B, C, D, E, F, G, H, I, J int
+}
+
+// TestStructWide More columns than the default grid of 26 once flattened
+type TestStructWide struct {
+	First  TestStructTenColumns
+	Second TestStructTenColumns
+	Third  TestStructTenColumns
+}
+
+func TestSelectIntoWideTable(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
+
+	table, err := db.CreateTable(TestStructWide{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	columns := table.header().Columns
+	if len(columns) != 30 || columns[29] != "Third.J" {
+		t.Fatalf("unexpected columns %v", columns)
+	}
+
+	expected := []TestStructWide{
+		{
+			First:  TestStructTenColumns{A: 1, J: 10},
+			Second: TestStructTenColumns{A: 11, J: 20},
+			Third:  TestStructTenColumns{A: 21, J: 30},
+		},
+	}
+	if err := table.UpsertIf([]interface{}{expected[0]}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := db.FindTable(TestStructWide{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(found.header().Columns, columns) {
+		t.Fatalf("expected columns %v, got %v", columns, found.header().Columns)
+	}
+	var selected []TestStructWide
+	if err := found.SelectInto(&selected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(selected, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, selected)
+	}
+}
@@ -0,0 +1,76 @@
+package gosheet
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/api/googleapi"
+)
+
+// Sentinel errors. Check them with errors.Is.
+var (
+	// ErrDatabaseNotFound No database file with the given title
+	ErrDatabaseNotFound = errors.New("gosheet: database not found")
+	// ErrDatabaseExists A database file with the given title already exists
+	ErrDatabaseExists = errors.New("gosheet: database already exists")
+	// ErrTableNotFound No table(a.k.a. sheet) with the given name
+	ErrTableNotFound = errors.New("gosheet: table not found")
+	// ErrTableExists A table(a.k.a. sheet) with the given name already exists
+	ErrTableExists = errors.New("gosheet: table already exists")
+	// ErrSchemaMismatch Values do not fit the table's scheme
+	ErrSchemaMismatch = errors.New("gosheet: schema mismatch")
+	// ErrUnsupportedType Struct has a field which cannot be stored as a column
+	ErrUnsupportedType = errors.New("gosheet: unsupported column type")
+	// ErrQuotaExceeded Google rejected the request with 429 Too Many Requests
+	ErrQuotaExceeded = errors.New("gosheet: api quota exceeded")
+	// ErrCorruptMetadata Header rows(names, types, rows/cols/constraints) of a table are unreadable
+	ErrCorruptMetadata = errors.New("gosheet: corrupt table metadata")
+	// ErrValueTooLarge A value does not fit in a cell, which holds 50,000 characters
+	ErrValueTooLarge = errors.New("gosheet: value too large for a cell")
+	// ErrInvalidBackup The archive given to Restore is unreadable or of an unsupported version
+	ErrInvalidBackup = errors.New("gosheet: invalid backup")
+)
+
+// APIError Describes a failed call to the Sheets or Drive API.
+// Unwraps to the underlying error, usually *googleapi.Error, so errors.As works on it.
+type APIError struct {
+	Op  string
+	Err error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("gosheet: %s: %v", e.Op, e.Err)
+}
+
+// Unwrap Returns the underlying error
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Is Reports ErrQuotaExceeded for 429 responses
+func (e *APIError) Is(target error) bool {
+	return target == ErrQuotaExceeded && e.StatusCode() == http.StatusTooManyRequests
+}
+
+// StatusCode HTTP status code of the failed call, or 0 if the call never got a response
+func (e *APIError) StatusCode() int {
+	var gerr *googleapi.Error
+	if errors.As(e.Err, &gerr) {
+		return gerr.Code
+	}
+	return 0
+}
+
+// apiError wraps an error returned by the Sheets or Drive API
+func apiError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &APIError{Op: op, Err: err}
+}
+
+// corruptMetadata wraps ErrCorruptMetadata with the reason
+func corruptMetadata(table string, format string, args ...interface{}) error {
+	return fmt.Errorf("%w: table %s: %s", ErrCorruptMetadata, table, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,50 @@
+package gosheet
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"google.golang.org/api/googleapi"
+)
+
+func TestAPIErrorQuota(t *testing.T) {
+	err := apiError("spreadsheets.values.get", &googleapi.Error{Code: http.StatusTooManyRequests})
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("429 should be ErrQuotaExceeded: %v", err)
+	}
+	var gerr *googleapi.Error
+	if !errors.As(err, &gerr) || gerr.Code != http.StatusTooManyRequests {
+		t.Fatalf("should unwrap to *googleapi.Error: %v", err)
+	}
+
+	err = apiError("spreadsheets.values.get", &googleapi.Error{Code: http.StatusNotFound})
+	if errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("404 should not be ErrQuotaExceeded: %v", err)
+	}
+}
+
+func TestCorruptMetadata(t *testing.T) {
+	header := [][]interface{}{
+		{"Yes", "Name"},
+		{"bool", "string"},
+		{"not a number", "2"},
+	}
+	if _, err := parseTableScheme("TestStructSmall", header); !errors.Is(err, ErrCorruptMetadata) {
+		t.Fatalf("expected ErrCorruptMetadata, got %v", err)
+	}
+
+	header[2] = []interface{}{"0", "2", "{broken"}
+	if _, err := parseTableScheme("TestStructSmall", header); !errors.Is(err, ErrCorruptMetadata) {
+		t.Fatalf("expected ErrCorruptMetadata, got %v", err)
+	}
+
+	header[2] = []interface{}{"3", "2", `{"uniqueColumns":["Name"]}`}
+	scheme, err := parseTableScheme("TestStructSmall", header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scheme.Rows != 3 || len(scheme.Columns) != 2 || scheme.Constraints.uniqueColumns[0] != "Name" {
+		t.Fatalf("unexpected scheme %+v", scheme)
+	}
+}
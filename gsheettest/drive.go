@@ -0,0 +1,627 @@
+package gsheettest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	defaultFileFields     = "kind,id,name,mimeType"
+	defaultFileListFields = "kind,incompleteSearch,nextPageToken,files(kind,id,name,mimeType)"
+)
+
+// serveDrive Routes /drive/v3/files...
+func (s *Server) serveDrive(w http.ResponseWriter, r *http.Request, segments []string) {
+	switch {
+	case len(segments) == 1 && r.Method == http.MethodGet:
+		s.listFiles(w, r)
+	case len(segments) == 1 && r.Method == http.MethodPost:
+		s.createFile(w, r)
+	case len(segments) == 2 && r.Method == http.MethodDelete:
+		id, _ := url.PathUnescape(segments[1])
+		s.deleteFile(w, r, id)
+	case len(segments) == 2 && r.Method == http.MethodPatch:
+		id, _ := url.PathUnescape(segments[1])
+		s.updateFile(w, r, id)
+	case len(segments) == 3 && segments[2] == "copy" && r.Method == http.MethodPost:
+		id, _ := url.PathUnescape(segments[1])
+		s.copyFile(w, r, id)
+	case len(segments) >= 3 && segments[2] == "permissions":
+		id, _ := url.PathUnescape(segments[1])
+		f, ok := s.driveFile(r, id)
+		if !ok {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("File not found: %s.", id))
+			return
+		}
+		s.servePermissions(w, r, f, segments[3:])
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Unknown method "+r.URL.Path)
+	}
+}
+
+// supportsAllDrives Whether `r` may reach files in shared drives
+func supportsAllDrives(r *http.Request) bool {
+	supports, _ := strconv.ParseBool(r.URL.Query().Get("supportsAllDrives"))
+	return supports
+}
+
+// driveFile File `id` if `r` may reach it
+func (s *Server) driveFile(r *http.Request, id string) (*file, bool) {
+	f, ok := s.files[id]
+	if !ok || (len(f.driveID) > 0 && !supportsAllDrives(r)) {
+		return nil, false
+	}
+	return f, true
+}
+
+// fileJSON File resource of `f`. Files in shared drives have no owners.
+func fileJSON(f *file) map[string]interface{} {
+	body := map[string]interface{}{
+		"kind":         "drive#file",
+		"id":           f.id,
+		"name":         f.name,
+		"mimeType":     f.mimeType,
+		"parents":      f.parents,
+		"trashed":      f.trashed,
+		"createdTime":  f.createdTime.Format(time.RFC3339Nano),
+		"modifiedTime": f.modifiedTime.Format(time.RFC3339Nano),
+	}
+	if len(f.driveID) > 0 {
+		body["driveId"] = f.driveID
+	} else {
+		body["owners"] = []interface{}{
+			map[string]interface{}{
+				"kind":         "drive#user",
+				"displayName":  "gsheettest",
+				"emailAddress": Owner,
+				"me":           true,
+			},
+		}
+	}
+	return body
+}
+
+// writeFileJSON Writes file resources with the default fields unless asked for others
+func writeFileJSON(w http.ResponseWriter, r *http.Request, body map[string]interface{}, defaultFields string) {
+	if len(r.URL.Query().Get("fields")) == 0 {
+		mask, _ := parseFieldMask(defaultFields)
+		writeJSON(w, r, mask.apply(body))
+		return
+	}
+	writeJSON(w, r, body)
+}
+
+// createFile POST /drive/v3/files, without content.
+// Spreadsheets are created with a sheet like Google Sheets does.
+func (s *Server) createFile(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name     string   `json:"name"`
+		MimeType string   `json:"mimeType"`
+		Parents  []string `json:"parents"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+	if len(body.Parents) > 1 {
+		writeError(w, http.StatusForbidden, "PERMISSION_DENIED", "Increasing the number of parents is not allowed")
+		return
+	}
+	parentID := RootFolderID
+	if len(body.Parents) == 1 {
+		parentID = body.Parents[0]
+	}
+	_, sharedDrive := s.drives[parentID]
+	if parent, ok := s.files[parentID]; ok && len(parent.driveID) > 0 {
+		sharedDrive = true
+	}
+	if sharedDrive && !supportsAllDrives(r) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("File not found: %s.", parentID))
+		return
+	}
+	if len(body.Name) == 0 {
+		body.Name = "Untitled"
+	}
+
+	f, err := s.newFile(body.Name, body.MimeType, parentID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+		return
+	}
+	if f.mimeType == spreadsheetMimeType {
+		f.spreadsheet = newSpreadsheet(f.name)
+		f.spreadsheet.id = f.id
+		f.spreadsheet.addSheet("Sheet1", nil, 0, 0)
+	}
+	writeFileJSON(w, r, fileJSON(f), defaultFileFields)
+}
+
+// listFiles GET /drive/v3/files
+func (s *Server) listFiles(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q, err := parseQuery(query.Get("q"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid Value")
+		return
+	}
+
+	pageSize := 100
+	if size := query.Get("pageSize"); len(size) > 0 {
+		if pageSize, err = strconv.Atoi(size); err != nil || pageSize <= 0 || pageSize > 1000 {
+			writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid value for pageSize: "+size)
+			return
+		}
+	}
+	offset := 0
+	if token := query.Get("pageToken"); len(token) > 0 {
+		if offset, err = strconv.Atoi(token); err != nil || offset < 0 {
+			writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid pageToken: "+token)
+			return
+		}
+	}
+
+	// https://developers.google.com/drive/api/v3/enable-shareddrives
+	allDrives, _ := strconv.ParseBool(query.Get("includeItemsFromAllDrives"))
+	if allDrives && !supportsAllDrives(r) {
+		writeError(w, http.StatusForbidden, "PERMISSION_DENIED", "The includeItemsFromAllDrives parameter can only be set when supportsAllDrives is set")
+		return
+	}
+	driveID := query.Get("driveId")
+	switch corpora := query.Get("corpora"); corpora {
+	case "", "user":
+		if len(driveID) > 0 {
+			writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "The driveId parameter must be specified if and only if corpora is set to drive")
+			return
+		}
+	case "drive":
+		if _, ok := s.drives[driveID]; !ok || !allDrives {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "Shared drive not found: "+driveID)
+			return
+		}
+	case "allDrives":
+		if !allDrives {
+			writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "includeItemsFromAllDrives must be set for corpora allDrives")
+			return
+		}
+	default:
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid value for corpora: "+corpora)
+		return
+	}
+
+	var matched []interface{}
+	for _, id := range s.order {
+		f, ok := s.files[id]
+		if !ok {
+			continue
+		}
+		if len(driveID) > 0 && f.driveID != driveID {
+			continue
+		}
+		if len(f.driveID) > 0 && !allDrives {
+			continue
+		}
+		if q == nil || q.eval(f) {
+			matched = append(matched, fileJSON(f))
+		}
+	}
+
+	body := map[string]interface{}{
+		"kind":             "drive#fileList",
+		"incompleteSearch": false,
+	}
+	if offset > len(matched) {
+		offset = len(matched)
+	}
+	end := offset + pageSize
+	if end < len(matched) {
+		body["nextPageToken"] = strconv.Itoa(end)
+	} else {
+		end = len(matched)
+	}
+	body["files"] = append([]interface{}{}, matched[offset:end]...)
+	writeFileJSON(w, r, body, defaultFileListFields)
+}
+
+// copyFile POST /drive/v3/files/{id}/copy. The copy goes to the folder of the original unless given.
+func (s *Server) copyFile(w http.ResponseWriter, r *http.Request, id string) {
+	original, ok := s.driveFile(r, id)
+	if !ok || original.mimeType == folderMimeType {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("File not found: %s.", id))
+		return
+	}
+	var body struct {
+		Name    string   `json:"name"`
+		Parents []string `json:"parents"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+	if len(body.Name) == 0 {
+		body.Name = "Copy of " + original.name
+	}
+	parentID := original.parents[0]
+	if len(body.Parents) > 0 {
+		parentID = body.Parents[0]
+	}
+
+	f, err := s.newFile(body.Name, original.mimeType, parentID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+		return
+	}
+	if original.spreadsheet != nil {
+		f.spreadsheet = original.spreadsheet.clone()
+		f.spreadsheet.id = f.id
+		f.spreadsheet.title = f.name
+	}
+	writeFileJSON(w, r, fileJSON(f), defaultFileFields)
+}
+
+// updateFile PATCH /drive/v3/files/{id}, renaming or trashing the file
+func (s *Server) updateFile(w http.ResponseWriter, r *http.Request, id string) {
+	f, ok := s.driveFile(r, id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("File not found: %s.", id))
+		return
+	}
+	var body struct {
+		Name    *string `json:"name"`
+		Trashed *bool   `json:"trashed"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+	if body.Name != nil {
+		f.name = *body.Name
+		if f.spreadsheet != nil {
+			f.spreadsheet.title = f.name
+		}
+		s.touch(f)
+	}
+	if body.Trashed != nil {
+		f.trashed = *body.Trashed
+	}
+	writeFileJSON(w, r, fileJSON(f), defaultFileFields)
+}
+
+// deleteFile DELETE /drive/v3/files/{id}
+func (s *Server) deleteFile(w http.ResponseWriter, r *http.Request, id string) {
+	if _, ok := s.driveFile(r, id); !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("File not found: %s.", id))
+		return
+	}
+	delete(s.files, id)
+	for i := range s.order {
+		if s.order[i] == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// query Parsed Drive search query
+// https://developers.google.com/drive/api/v3/ref-search-terms
+type query interface {
+	eval(f *file) bool
+}
+
+type andQuery []query
+type orQuery []query
+type notQuery struct{ q query }
+
+// term `field op value` or `value in field`
+type term struct {
+	field, op string
+	value     interface{}
+}
+
+func (q andQuery) eval(f *file) bool {
+	for _, sub := range q {
+		if !sub.eval(f) {
+			return false
+		}
+	}
+	return true
+}
+
+func (q orQuery) eval(f *file) bool {
+	for _, sub := range q {
+		if sub.eval(f) {
+			return true
+		}
+	}
+	return false
+}
+
+func (q notQuery) eval(f *file) bool {
+	return !q.q.eval(f)
+}
+
+func (t term) eval(f *file) bool {
+	switch t.field {
+	case "name":
+		return compareString(f.name, t.op, t.value, true)
+	case "fullText":
+		return compareString(f.name, t.op, t.value, false)
+	case "mimeType":
+		return compareString(f.mimeType, t.op, t.value, false)
+	case "trashed":
+		b, ok := t.value.(bool)
+		return ok && (t.op == "=") == (f.trashed == b)
+	case "starred":
+		b, ok := t.value.(bool)
+		return ok && (t.op == "=") == !b
+	case "createdTime", "modifiedTime":
+		str, ok := t.value.(string)
+		if !ok {
+			return false
+		}
+		target, err := time.Parse(time.RFC3339Nano, str)
+		if err != nil {
+			return false
+		}
+		actual := f.createdTime
+		if t.field == "modifiedTime" {
+			actual = f.modifiedTime
+		}
+		return compareOrdered(actual.Sub(target), t.op)
+	case "parents":
+		id, ok := t.value.(string)
+		if !ok || t.op != "in" {
+			return false
+		}
+		for _, parent := range f.parents {
+			if parent == id {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// compareString `contains` on names matches word prefixes like Drive does
+func compareString(actual, op string, value interface{}, prefixOnly bool) bool {
+	str, ok := value.(string)
+	if !ok {
+		return false
+	}
+	switch op {
+	case "=":
+		return actual == str
+	case "!=":
+		return actual != str
+	case "contains":
+		if !prefixOnly {
+			return strings.Contains(strings.ToLower(actual), strings.ToLower(str))
+		}
+		lowered, prefix := strings.ToLower(actual), strings.ToLower(str)
+		if strings.HasPrefix(lowered, prefix) {
+			return true
+		}
+		for _, word := range strings.Fields(lowered) {
+			if strings.HasPrefix(word, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	return compareOrdered(time.Duration(strings.Compare(actual, str)), op)
+}
+
+func compareOrdered(diff time.Duration, op string) bool {
+	switch op {
+	case "=":
+		return diff == 0
+	case "!=":
+		return diff != 0
+	case "<":
+		return diff < 0
+	case "<=":
+		return diff <= 0
+	case ">":
+		return diff > 0
+	case ">=":
+		return diff >= 0
+	}
+	return false
+}
+
+// parseQuery Parses `q`. Empty query matches everything and returns nil.
+func parseQuery(q string) (query, error) {
+	p := &queryParser{input: q}
+	p.skipSpaces()
+	if p.pos == len(p.input) {
+		return nil, nil
+	}
+	parsed, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if p.pos != len(p.input) {
+		return nil, fmt.Errorf("unexpected %q in query", p.input[p.pos:])
+	}
+	return parsed, nil
+}
+
+type queryParser struct {
+	input string
+	pos   int
+}
+
+func (p *queryParser) skipSpaces() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// keyword Consumes `word` if it is next
+func (p *queryParser) keyword(word string) bool {
+	p.skipSpaces()
+	end := p.pos + len(word)
+	if end > len(p.input) || !strings.EqualFold(p.input[p.pos:end], word) {
+		return false
+	}
+	if end < len(p.input) && isIdentRune(rune(p.input[end])) && isIdentRune(rune(word[len(word)-1])) {
+		return false
+	}
+	p.pos = end
+	return true
+}
+
+func isIdentRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.'
+}
+
+// or := and ('or' and)*
+func (p *queryParser) or() (query, error) {
+	first, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	q := orQuery{first}
+	for p.keyword("or") {
+		next, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		q = append(q, next)
+	}
+	if len(q) == 1 {
+		return first, nil
+	}
+	return q, nil
+}
+
+// and := unary ('and' unary)*
+func (p *queryParser) and() (query, error) {
+	first, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	q := andQuery{first}
+	for p.keyword("and") {
+		next, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		q = append(q, next)
+	}
+	if len(q) == 1 {
+		return first, nil
+	}
+	return q, nil
+}
+
+// unary := 'not' unary | '(' or ')' | term
+func (p *queryParser) unary() (query, error) {
+	if p.keyword("not") {
+		q, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return notQuery{q}, nil
+	}
+	if p.keyword("(") {
+		q, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.keyword(")") {
+			return nil, fmt.Errorf("unclosed parenthesis in query")
+		}
+		return q, nil
+	}
+	return p.term()
+}
+
+// term := value 'in' field | field op value
+func (p *queryParser) term() (query, error) {
+	p.skipSpaces()
+	if p.pos < len(p.input) && p.input[p.pos] == '\'' {
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		if !p.keyword("in") {
+			return nil, fmt.Errorf("expected 'in' in query")
+		}
+		field := p.identifier()
+		if len(field) == 0 {
+			return nil, fmt.Errorf("expected field in query")
+		}
+		return term{field: field, op: "in", value: value}, nil
+	}
+
+	field := p.identifier()
+	if len(field) == 0 {
+		return nil, fmt.Errorf("expected field in query at %d", p.pos)
+	}
+	var op string
+	for _, candidate := range []string{"contains", "!=", "<=", ">=", "=", "<", ">"} {
+		if p.keyword(candidate) {
+			op = candidate
+			break
+		}
+	}
+	if len(op) == 0 {
+		return nil, fmt.Errorf("expected operator in query at %d", p.pos)
+	}
+	value, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	return term{field: field, op: op, value: value}, nil
+}
+
+func (p *queryParser) identifier() string {
+	p.skipSpaces()
+	start := p.pos
+	for p.pos < len(p.input) && isIdentRune(rune(p.input[p.pos])) {
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
+
+// value := quoted string with \' and \\ escapes | true | false | number
+func (p *queryParser) value() (interface{}, error) {
+	p.skipSpaces()
+	if p.pos < len(p.input) && p.input[p.pos] == '\'' {
+		var sb strings.Builder
+		for p.pos++; p.pos < len(p.input); p.pos++ {
+			c := p.input[p.pos]
+			switch {
+			case c == '\\' && p.pos+1 < len(p.input):
+				p.pos++
+				sb.WriteByte(p.input[p.pos])
+			case c == '\'':
+				p.pos++
+				return sb.String(), nil
+			default:
+				sb.WriteByte(c)
+			}
+		}
+		return nil, fmt.Errorf("unterminated string in query")
+	}
+	word := p.identifier()
+	switch strings.ToLower(word) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	if n, err := strconv.ParseFloat(word, 64); err == nil {
+		return n, nil
+	}
+	return nil, fmt.Errorf("invalid value %q in query", word)
+}
@@ -0,0 +1,131 @@
+package gsheettest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// fieldMask Partial response selector parsed from the `fields` parameter.
+// A nil sub mask selects the whole value.
+// https://developers.google.com/sheets/api/guides/field-masks
+type fieldMask map[string]fieldMask
+
+// parseFieldMask Parses masks like "spreadsheetId,sheets.properties" or "files(id,name),nextPageToken"
+func parseFieldMask(fields string) (fieldMask, error) {
+	p := &fieldMaskParser{input: strings.Replace(fields, " ", "", -1)}
+	mask, err := p.list()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.input) {
+		return nil, fmt.Errorf("invalid field mask %q at %d", fields, p.pos)
+	}
+	return mask, nil
+}
+
+type fieldMaskParser struct {
+	input string
+	pos   int
+}
+
+// list := item (',' item)*
+func (p *fieldMaskParser) list() (fieldMask, error) {
+	mask := make(fieldMask)
+	for {
+		if err := p.item(mask); err != nil {
+			return nil, err
+		}
+		if p.pos < len(p.input) && p.input[p.pos] == ',' {
+			p.pos++
+			continue
+		}
+		return mask, nil
+	}
+}
+
+// item := name (('/'|'.') name)* ['(' list ')']
+func (p *fieldMaskParser) item(mask fieldMask) error {
+	var path []string
+	for {
+		start := p.pos
+		for p.pos < len(p.input) && !strings.ContainsRune(",()/.", rune(p.input[p.pos])) {
+			p.pos++
+		}
+		if start == p.pos {
+			return fmt.Errorf("invalid field mask %q at %d", p.input, p.pos)
+		}
+		path = append(path, p.input[start:p.pos])
+		if p.pos < len(p.input) && (p.input[p.pos] == '/' || p.input[p.pos] == '.') {
+			p.pos++
+			continue
+		}
+		break
+	}
+
+	var sub fieldMask
+	if p.pos < len(p.input) && p.input[p.pos] == '(' {
+		p.pos++
+		var err error
+		sub, err = p.list()
+		if err != nil {
+			return err
+		}
+		if p.pos >= len(p.input) || p.input[p.pos] != ')' {
+			return fmt.Errorf("unclosed parenthesis in field mask %q", p.input)
+		}
+		p.pos++
+	}
+
+	// a/b/c(sub) -> {a: {b: {c: sub}}}
+	for i := len(path) - 1; i > 0; i-- {
+		sub = fieldMask{path[i]: sub}
+	}
+	mask.merge(path[0], sub)
+	return nil
+}
+
+func (mask fieldMask) merge(name string, sub fieldMask) {
+	existing, ok := mask[name]
+	if !ok {
+		mask[name] = sub
+		return
+	}
+	if existing == nil || sub == nil {
+		mask[name] = nil
+		return
+	}
+	for k, v := range sub {
+		existing.merge(k, v)
+	}
+}
+
+// apply Keeps only the selected fields of decoded JSON `value`
+func (mask fieldMask) apply(value interface{}) interface{} {
+	if mask == nil {
+		return value
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{})
+		for name, sub := range mask {
+			if name == "*" {
+				for k, field := range v {
+					result[k] = sub.apply(field)
+				}
+				continue
+			}
+			if field, ok := v[name]; ok {
+				result[name] = sub.apply(field)
+			}
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i := range v {
+			result[i] = mask.apply(v[i])
+		}
+		return result
+	default:
+		return value
+	}
+}
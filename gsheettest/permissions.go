@@ -0,0 +1,157 @@
+package gsheettest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPermissionFields     = "kind,id,type,role"
+	defaultPermissionListFields = "kind,nextPageToken,permissions(kind,id,type,role)"
+)
+
+var (
+	permissionRoles = map[string]bool{"owner": true, "organizer": true, "fileOrganizer": true, "writer": true, "commenter": true, "reader": true}
+	permissionTypes = map[string]bool{"user": true, "group": true, "domain": true, "anyone": true}
+)
+
+// servePermissions Routes /drive/v3/files/{id}/permissions...
+func (s *Server) servePermissions(w http.ResponseWriter, r *http.Request, f *file, segments []string) {
+	switch {
+	case len(segments) == 0 && r.Method == http.MethodGet:
+		s.listPermissions(w, r, f)
+	case len(segments) == 0 && r.Method == http.MethodPost:
+		s.createPermission(w, r, f)
+	case len(segments) == 1 && r.Method == http.MethodDelete:
+		id, _ := url.PathUnescape(segments[0])
+		s.deletePermission(w, r, f, id)
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Unknown method "+r.URL.Path)
+	}
+}
+
+// permissionJSON Permission resource of `p`
+func permissionJSON(p *permission) map[string]interface{} {
+	body := map[string]interface{}{
+		"kind": "drive#permission",
+		"id":   p.id,
+		"type": p.kind,
+		"role": p.role,
+	}
+	if len(p.emailAddress) > 0 {
+		body["emailAddress"] = p.emailAddress
+	}
+	if len(p.domain) > 0 {
+		body["domain"] = p.domain
+	}
+	return body
+}
+
+// listPermissions GET /drive/v3/files/{id}/permissions
+func (s *Server) listPermissions(w http.ResponseWriter, r *http.Request, f *file) {
+	query := r.URL.Query()
+	pageSize := 100
+	if size := query.Get("pageSize"); len(size) > 0 {
+		var err error
+		if pageSize, err = strconv.Atoi(size); err != nil || pageSize <= 0 || pageSize > 100 {
+			writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid value for pageSize: "+size)
+			return
+		}
+	}
+	offset := 0
+	if token := query.Get("pageToken"); len(token) > 0 {
+		var err error
+		if offset, err = strconv.Atoi(token); err != nil || offset < 0 || offset > len(f.permissions) {
+			writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid pageToken: "+token)
+			return
+		}
+	}
+
+	body := map[string]interface{}{"kind": "drive#permissionList"}
+	end := offset + pageSize
+	if end < len(f.permissions) {
+		body["nextPageToken"] = strconv.Itoa(end)
+	} else {
+		end = len(f.permissions)
+	}
+	permissions := make([]interface{}, 0, end-offset)
+	for _, p := range f.permissions[offset:end] {
+		permissions = append(permissions, permissionJSON(p))
+	}
+	body["permissions"] = permissions
+	writeFileJSON(w, r, body, defaultPermissionListFields)
+}
+
+// createPermission POST /drive/v3/files/{id}/permissions.
+// Granting the same user, group or domain again changes the role of the permission.
+func (s *Server) createPermission(w http.ResponseWriter, r *http.Request, f *file) {
+	var body struct {
+		Type         string `json:"type"`
+		Role         string `json:"role"`
+		EmailAddress string `json:"emailAddress"`
+		Domain       string `json:"domain"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+	switch {
+	case !permissionTypes[body.Type]:
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid permission type: "+body.Type)
+		return
+	case !permissionRoles[body.Role]:
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid permission role: "+body.Role)
+		return
+	case body.Role == "owner":
+		writeError(w, http.StatusForbidden, "PERMISSION_DENIED", "The transferOwnership parameter must be enabled when the permission role is 'owner'.")
+		return
+	case (body.Type == "user" || body.Type == "group") && !strings.Contains(body.EmailAddress, "@"):
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", fmt.Sprintf("Invalid email address: %q", body.EmailAddress))
+		return
+	case body.Type == "domain" && len(body.Domain) == 0:
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "A domain must be specified for domain permissions.")
+		return
+	}
+
+	for _, p := range f.permissions {
+		if p.kind == body.Type && strings.EqualFold(p.emailAddress, body.EmailAddress) && strings.EqualFold(p.domain, body.Domain) {
+			if p.role == "owner" {
+				writeError(w, http.StatusForbidden, "PERMISSION_DENIED", "The owner of a file cannot be downgraded.")
+				return
+			}
+			p.role = body.Role
+			writeFileJSON(w, r, permissionJSON(p), defaultPermissionFields)
+			return
+		}
+	}
+	p := &permission{
+		id:           s.newID("permission"),
+		kind:         body.Type,
+		role:         body.Role,
+		emailAddress: body.EmailAddress,
+		domain:       body.Domain,
+	}
+	f.permissions = append(f.permissions, p)
+	writeFileJSON(w, r, permissionJSON(p), defaultPermissionFields)
+}
+
+// deletePermission DELETE /drive/v3/files/{id}/permissions/{permissionId}
+func (s *Server) deletePermission(w http.ResponseWriter, r *http.Request, f *file, id string) {
+	for i, p := range f.permissions {
+		if p.id != id {
+			continue
+		}
+		if p.role == "owner" {
+			writeError(w, http.StatusForbidden, "PERMISSION_DENIED", "The owner of a file cannot be removed.")
+			return
+		}
+		f.permissions = append(f.permissions[:i], f.permissions[i+1:]...)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeError(w, http.StatusNotFound, "NOT_FOUND", "Permission not found: "+id+".")
+}
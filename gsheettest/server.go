@@ -0,0 +1,332 @@
+// Package gsheettest provides an in-process fake of the Google Sheets v4 and Drive v3 APIs
+// for hermetic tests.
+//
+// The fake keeps spreadsheets in memory and serves the endpoints used by gosheet:
+// spreadsheets get/create/batchUpdate, values get/batchUpdate/clear/append,
+// Drive files create/copy/list/update/delete in My Drive, folders and shared drives,
+// and Drive permissions create/list/delete.
+//
+//	server := gsheettest.NewServer()
+//	defer server.Close()
+//	manager, err := gosheet.NewSheetManagerFromTokenSource(server.TokenSource(),
+//		gosheet.WithHTTPClient(server.Client()),
+//		gosheet.WithSheetsEndpoint(server.SheetsEndpoint()),
+//		gosheet.WithDriveEndpoint(server.DriveEndpoint()))
+package gsheettest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	spreadsheetMimeType = "application/vnd.google-apps.spreadsheet"
+	folderMimeType      = "application/vnd.google-apps.folder"
+)
+
+// RootFolderID ID of the root folder of My Drive. Drive also accepts the alias "root".
+const RootFolderID = "root"
+
+// Owner Email address of the owner of every file in the fake
+const Owner = "owner@gsheettest.example.com"
+
+// Request A request received by the fake
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+}
+
+// Server Fake Sheets and Drive server
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	files    map[string]*file
+	order    []string          // file ids by creation
+	drives   map[string]string // names of shared drives by id
+	nextID   int64
+	requests []Request
+	faults   []Fault
+	now      func() time.Time
+}
+
+// Fault An error response injected with InjectFault
+type Fault struct {
+	// Status HTTP status code, e.g. 429 or 503
+	Status int
+	// RetryAfter Sets the Retry-After header in seconds if positive
+	RetryAfter time.Duration
+	// Method Only requests with this method get the fault. Any request if empty.
+	Method string
+}
+
+// file A Drive file. Spreadsheets keep their cells in `spreadsheet`.
+type file struct {
+	id           string
+	name         string
+	mimeType     string
+	parents      []string
+	driveID      string // shared drive of the file, empty in My Drive
+	trashed      bool
+	createdTime  time.Time
+	modifiedTime time.Time
+	permissions  []*permission
+	spreadsheet  *spreadsheet
+}
+
+// permission Access to a file granted to a user, group, domain or anyone
+type permission struct {
+	id, kind, role string
+	emailAddress   string
+	domain         string
+}
+
+// NewServer Starts a new fake server. Close it when done.
+func NewServer() *Server {
+	s := &Server{
+		files:  make(map[string]*file),
+		drives: make(map[string]string),
+		now:    time.Now,
+	}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// SheetsEndpoint Base URL of the fake Sheets API
+func (s *Server) SheetsEndpoint() string {
+	return s.URL + "/"
+}
+
+// DriveEndpoint Base URL of the fake Drive v3 API
+func (s *Server) DriveEndpoint() string {
+	return s.URL + "/drive/v3/"
+}
+
+// TokenSource Token source accepted by the fake
+func (s *Server) TokenSource() oauth2.TokenSource {
+	return oauth2.StaticTokenSource(&oauth2.Token{
+		AccessToken: "gsheettest",
+		TokenType:   "Bearer",
+		Expiry:      time.Now().Add(24 * time.Hour),
+	})
+}
+
+// AddFolder Creates a folder in `parentID`, which is a folder, a shared drive or RootFolderID.
+// Returns the id of the folder.
+func (s *Server) AddFolder(name, parentID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.newFile(name, folderMimeType, parentID)
+	if err != nil {
+		panic(err)
+	}
+	return f.id
+}
+
+// AddSharedDrive Creates a shared drive and returns its id.
+// Files in it are reached only by requests with supportsAllDrives=true.
+func (s *Server) AddSharedDrive(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.newID("drive")
+	s.drives[id] = name
+	return id
+}
+
+// Requests Requests received so far, oldest first
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests := make([]Request, len(s.requests))
+	copy(requests, s.requests)
+	return requests
+}
+
+// ResetRequests Forgets received requests
+func (s *Server) ResetRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+}
+
+// InjectFault Answers the next `n` requests with `fault` instead of serving them
+func (s *Server) InjectFault(n int, fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.faults = append(s.faults, fault)
+	}
+}
+
+// ServeHTTP Routes requests to the Sheets or Drive handlers
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+	})
+
+	for i, fault := range s.faults {
+		if len(fault.Method) > 0 && fault.Method != r.Method {
+			continue
+		}
+		s.faults = append(s.faults[:i], s.faults[i+1:]...)
+		if fault.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.FormatInt(int64(fault.RetryAfter/time.Second), 10))
+		}
+		writeError(w, fault.Status, faultStatus(fault.Status), http.StatusText(fault.Status))
+		return
+	}
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		writeError(w, http.StatusUnauthorized, "UNAUTHENTICATED", "Request is missing required authentication credential.")
+		return
+	}
+
+	segments := strings.Split(strings.Trim(r.URL.EscapedPath(), "/"), "/")
+	switch {
+	case len(segments) >= 2 && segments[0] == "v4" && strings.HasPrefix(segments[1], "spreadsheets"):
+		s.serveSheets(w, r, segments[1:])
+	case len(segments) >= 3 && segments[0] == "drive" && segments[1] == "v3" && segments[2] == "files":
+		s.serveDrive(w, r, segments[2:])
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("Unknown path %s", r.URL.Path))
+	}
+}
+
+// newID New unique id of a file
+func (s *Server) newID(prefix string) string {
+	s.nextID++
+	return fmt.Sprintf("%s-%04d", prefix, s.nextID)
+}
+
+// newFile Adds a file to the folder or shared drive `parentID`
+func (s *Server) newFile(name, mimeType, parentID string) (*file, error) {
+	driveID := ""
+	if _, ok := s.drives[parentID]; ok {
+		driveID = parentID
+	} else if parent, ok := s.files[parentID]; ok && parent.mimeType == folderMimeType {
+		driveID = parent.driveID
+	} else if parentID != RootFolderID {
+		return nil, fmt.Errorf("File not found: %s.", parentID)
+	}
+
+	prefix := "file"
+	switch mimeType {
+	case spreadsheetMimeType:
+		prefix = "spreadsheet"
+	case folderMimeType:
+		prefix = "folder"
+	}
+	now := s.now().UTC()
+	f := &file{
+		id:           s.newID(prefix),
+		name:         name,
+		mimeType:     mimeType,
+		parents:      []string{parentID},
+		driveID:      driveID,
+		createdTime:  now,
+		modifiedTime: now,
+	}
+	if len(driveID) == 0 {
+		f.permissions = []*permission{{id: s.newID("permission"), kind: "user", role: "owner", emailAddress: Owner}}
+	}
+	s.files[f.id] = f
+	s.order = append(s.order, f.id)
+	return f, nil
+}
+
+// spreadsheetFile Live file of spreadsheet `id`
+func (s *Server) spreadsheetFile(id string) (*file, bool) {
+	f, ok := s.files[id]
+	if !ok || f.spreadsheet == nil {
+		return nil, false
+	}
+	return f, true
+}
+
+// touch Updates modified time of `f`
+func (s *Server) touch(f *file) {
+	f.modifiedTime = s.now().UTC()
+}
+
+// writeJSON Writes `body` applying the partial response field mask of `r`
+func writeJSON(w http.ResponseWriter, r *http.Request, body interface{}) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL", err.Error())
+		return
+	}
+	if fields := r.URL.Query().Get("fields"); len(fields) > 0 {
+		mask, err := parseFieldMask(fields)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+			return
+		}
+		var generic interface{}
+		json.Unmarshal(raw, &generic)
+		raw, _ = json.Marshal(mask.apply(generic))
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Write(raw)
+}
+
+// faultStatus Status name of Google APIs for an HTTP status code
+func faultStatus(code int) string {
+	switch code {
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	case http.StatusGatewayTimeout:
+		return "DEADLINE_EXCEEDED"
+	case http.StatusBadRequest:
+		return "INVALID_ARGUMENT"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	}
+	return "INTERNAL"
+}
+
+// writeError Writes an error in the format of Google APIs
+func writeError(w http.ResponseWriter, code int, status, message string) {
+	reason := map[int]string{
+		http.StatusBadRequest:      "badRequest",
+		http.StatusUnauthorized:    "authError",
+		http.StatusForbidden:       "forbidden",
+		http.StatusNotFound:        "notFound",
+		http.StatusTooManyRequests: "rateLimitExceeded",
+	}[code]
+	if len(reason) == 0 {
+		reason = "backendError"
+	}
+	body := map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    code,
+			"message": message,
+			"status":  status,
+			"errors": []map[string]string{
+				{"message": message, "domain": "global", "reason": reason},
+			},
+		},
+	}
+	raw, _ := json.Marshal(body)
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(code)
+	w.Write(raw)
+}
@@ -0,0 +1,390 @@
+package gsheettest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	"google.golang.org/api/sheets/v4"
+)
+
+// newTestService Sheets service authorized for `server`
+func newTestService(t *testing.T, server *Server) (*sheets.Service, *http.Client) {
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, server.Client())
+	client := oauth2.NewClient(ctx, server.TokenSource())
+	service, err := sheets.NewService(context.Background(),
+		option.WithHTTPClient(client),
+		option.WithEndpoint(server.SheetsEndpoint()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service, client
+}
+
+func statusCode(err error) int {
+	if apiErr, ok := err.(*googleapi.Error); ok {
+		return apiErr.Code
+	}
+	return 0
+}
+
+func TestUnauthorized(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL + "/v4/spreadsheets/any")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", resp.StatusCode)
+	}
+}
+
+func TestSpreadsheetLifecycle(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	service, client := newTestService(t, server)
+
+	created, err := service.Spreadsheets.Create(&sheets.Spreadsheet{
+		Properties: &sheets.SpreadsheetProperties{Title: "fake", TimeZone: "Asia/Seoul"},
+	}).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Properties.Title != "fake" || created.Properties.TimeZone != "Asia/Seoul" || len(created.Sheets) != 1 {
+		t.Fatalf("unexpected spreadsheet %+v", created.Properties)
+	}
+	id := created.SpreadsheetId
+
+	_, err = service.Spreadsheets.BatchUpdate(id, &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{
+			{AddSheet: &sheets.AddSheetRequest{Properties: &sheets.SheetProperties{Title: "Table"}}},
+			{AddSheet: &sheets.AddSheetRequest{Properties: &sheets.SheetProperties{Title: "Table"}}},
+		},
+	}).Do()
+	if statusCode(err) != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a duplicated sheet, got %v", err)
+	}
+
+	resp, err := service.Spreadsheets.BatchUpdate(id, &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{
+			{AddSheet: &sheets.AddSheetRequest{Properties: &sheets.SheetProperties{Title: "Table"}}},
+		},
+		IncludeSpreadsheetInResponse: true,
+	}).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(resp.UpdatedSpreadsheet.Sheets); n != 2 {
+		t.Fatalf("failed batch update should not be applied, got %d sheets", n)
+	}
+
+	got, err := service.Spreadsheets.Get(id).Fields("spreadsheetId,sheets.properties.title").Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Properties != nil || len(got.Sheets) != 2 || got.Sheets[1].Properties.Title != "Table" || got.Sheets[1].Properties.SheetId != 0 {
+		t.Fatalf("field mask not applied: %+v", got)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, server.DriveEndpoint()+"files/"+id, nil)
+	deleted, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted.Body.Close()
+	if deleted.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", deleted.StatusCode)
+	}
+	if _, err := service.Spreadsheets.Get(id).Do(); statusCode(err) != http.StatusNotFound {
+		t.Fatalf("expected 404, got %v", err)
+	}
+}
+
+func TestValues(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	service, _ := newTestService(t, server)
+
+	created, err := service.Spreadsheets.Create(&sheets.Spreadsheet{}).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := created.SpreadsheetId
+
+	_, err = service.Spreadsheets.Values.BatchUpdate(id, &sheets.BatchUpdateValuesRequest{
+		ValueInputOption: "RAW",
+		Data: []*sheets.ValueRange{
+			{Range: "Sheet1!A1:C2", Values: [][]interface{}{{"a", int64(1), true}, {"b", 2.5, ""}}},
+			{Range: "Sheet1!E5", Values: [][]interface{}{{"x", "y"}, {"z"}}},
+		},
+	}).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := service.Spreadsheets.Values.Get(id, "Sheet1!A1:F6").Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]interface{}{
+		{"a", "1", "TRUE"},
+		{"b", "2.5"},
+		{},
+		{},
+		{"", "", "", "", "x", "y"},
+		{"", "", "", "", "z"},
+	}
+	if !reflect.DeepEqual(got.Values, expected) {
+		t.Fatalf("unexpected values %v", got.Values)
+	}
+
+	unformatted, err := service.Spreadsheets.Values.Get(id, "Sheet1!B1:B2").ValueRenderOption("UNFORMATTED_VALUE").Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(unformatted.Values, [][]interface{}{{1.0}, {2.5}}) {
+		t.Fatalf("unexpected unformatted values %v", unformatted.Values)
+	}
+
+	_, err = service.Spreadsheets.Values.BatchUpdate(id, &sheets.BatchUpdateValuesRequest{
+		ValueInputOption: "RAW",
+		Data:             []*sheets.ValueRange{{Range: "Sheet1!A1:B1", Values: [][]interface{}{{"1", "2", "3"}}}},
+	}).Do()
+	if statusCode(err) != http.StatusBadRequest {
+		t.Fatalf("expected 400 writing outside the range, got %v", err)
+	}
+	if _, err := service.Spreadsheets.Values.Get(id, "Sheet1!A1:AA3").Do(); statusCode(err) != http.StatusBadRequest {
+		t.Fatalf("expected 400 reading beyond grid limits, got %v", err)
+	}
+	// cells are scalars
+	for _, v := range []interface{}{map[string]interface{}{"City": "X"}, []interface{}{"a"}} {
+		_, err = service.Spreadsheets.Values.BatchUpdate(id, &sheets.BatchUpdateValuesRequest{
+			ValueInputOption: "RAW",
+			Data:             []*sheets.ValueRange{{Range: "Sheet1!A1", Values: [][]interface{}{{"1", v}}}},
+		}).Do()
+		if statusCode(err) != http.StatusBadRequest {
+			t.Fatalf("expected 400 writing %v, got %v", v, err)
+		}
+		_, err = service.Spreadsheets.Values.Append(id, "Sheet1!A1", &sheets.ValueRange{
+			Values: [][]interface{}{{v}},
+		}).ValueInputOption("RAW").Do()
+		if statusCode(err) != http.StatusBadRequest {
+			t.Fatalf("expected 400 appending %v, got %v", v, err)
+		}
+	}
+
+	if _, err := service.Spreadsheets.Values.Clear(id, "Sheet1!A1:A2", &sheets.ClearValuesRequest{}).Do(); err != nil {
+		t.Fatal(err)
+	}
+	appended, err := service.Spreadsheets.Values.Append(id, "Sheet1!A1", &sheets.ValueRange{
+		Values: [][]interface{}{{"appended"}},
+	}).ValueInputOption("USER_ENTERED").Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if appended.Updates.UpdatedRange != "Sheet1!A7:A7" {
+		t.Fatalf("unexpected appended range %s", appended.Updates.UpdatedRange)
+	}
+
+	column, err := service.Spreadsheets.Values.Get(id, "Sheet1!A:A").Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(column.Values, [][]interface{}{{}, {}, {}, {}, {}, {}, {"appended"}}) {
+		t.Fatalf("unexpected column %v", column.Values)
+	}
+}
+
+func TestParseA1(t *testing.T) {
+	ss := &spreadsheet{}
+	ss.addSheet("Sheet1", nil, 0, 0)
+	ss.addSheet("My Sheet", nil, 0, 0)
+
+	cases := []struct {
+		a1       string
+		expected string
+	}{
+		{"Sheet1!A1:D4", "Sheet1!A1:D4"},
+		{"'My Sheet'!B2", "'My Sheet'!B2:B2"},
+		{"Sheet1!A:B", "Sheet1!A1:B1000"},
+		{"Sheet1!2:3", "Sheet1!A2:Z3"},
+		{"Sheet1", "Sheet1!A1:Z1000"},
+		{"C3:AA30", "Sheet1!C3:AA30"},
+	}
+	for _, c := range cases {
+		g, err := parseA1(ss, c.a1)
+		if err != nil {
+			t.Fatalf("%s: %v", c.a1, err)
+		}
+		if g.String() != c.expected {
+			t.Fatalf("%s: expected %s, got %s", c.a1, c.expected, g.String())
+		}
+	}
+	for _, invalid := range []string{"Missing!A1", "Sheet1!A1:B2:C3", "Sheet1!1A", "Sheet1!B2:A1"} {
+		if _, err := parseA1(ss, invalid); err == nil {
+			t.Fatalf("%s should be invalid", invalid)
+		}
+	}
+}
+
+func TestListFiles(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	service, client := newTestService(t, server)
+
+	for _, title := range []string{"database_file_a", "database_file_b", "other", "it's"} {
+		if _, err := service.Spreadsheets.Create(&sheets.Spreadsheet{
+			Properties: &sheets.SpreadsheetProperties{Title: title},
+		}).Do(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list := func(query map[string]string) (names []string, nextPageToken string) {
+		req, _ := http.NewRequest(http.MethodGet, server.DriveEndpoint()+"files", nil)
+		q := req.URL.Query()
+		for k, v := range query {
+			q.Set(k, v)
+		}
+		req.URL.RawQuery = q.Encode()
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%v: status %d", query, resp.StatusCode)
+		}
+		var body struct {
+			NextPageToken string `json:"nextPageToken"`
+			Files         []struct {
+				Name string `json:"name"`
+			} `json:"files"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range body.Files {
+			names = append(names, f.Name)
+		}
+		return names, body.NextPageToken
+	}
+
+	names, _ := list(map[string]string{"q": "name contains 'database_file_' and trashed = false"})
+	if !reflect.DeepEqual(names, []string{"database_file_a", "database_file_b"}) {
+		t.Fatalf("unexpected files %v", names)
+	}
+	names, _ = list(map[string]string{"q": `name = 'it\'s' or (not name contains 'database' and mimeType != 'x')`})
+	if !reflect.DeepEqual(names, []string{"other", "it's"}) {
+		t.Fatalf("unexpected files %v", names)
+	}
+
+	names, token := list(map[string]string{"pageSize": "3"})
+	if len(names) != 3 || len(token) == 0 {
+		t.Fatalf("unexpected first page %v %q", names, token)
+	}
+	names, token = list(map[string]string{"pageSize": "3", "pageToken": token})
+	if !reflect.DeepEqual(names, []string{"it's"}) || len(token) != 0 {
+		t.Fatalf("unexpected second page %v %q", names, token)
+	}
+
+	resp, err := client.Get(server.DriveEndpoint() + "files?q=" + "name%20%3D")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid query, got %d", resp.StatusCode)
+	}
+}
+
+func TestFoldersAndSharedDrives(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	_, client := newTestService(t, server)
+
+	driveID := server.AddSharedDrive("team")
+	folderID := server.AddFolder("databases", RootFolderID)
+	sharedFolderID := server.AddFolder("shared", driveID)
+
+	create := func(name, parent, query string) (int, map[string]interface{}) {
+		raw, _ := json.Marshal(map[string]interface{}{
+			"name":     name,
+			"mimeType": "application/vnd.google-apps.spreadsheet",
+			"parents":  []string{parent},
+		})
+		resp, err := client.Post(server.DriveEndpoint()+"files?"+query, "application/json", bytes.NewReader(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var body map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body
+	}
+	if status, _ := create("in shared drive", sharedFolderID, ""); status != http.StatusNotFound {
+		t.Fatalf("shared drive reached without supportsAllDrives: %d", status)
+	}
+	status, created := create("in shared drive", sharedFolderID, "supportsAllDrives=true&fields=id,driveId,parents")
+	if status != http.StatusOK || created["driveId"] != driveID {
+		t.Fatalf("unexpected file %d %v", status, created)
+	}
+	if status, _ := create("in folder", folderID, ""); status != http.StatusOK {
+		t.Fatalf("unexpected status %d", status)
+	}
+
+	list := func(query string) (names []string, status int) {
+		resp, err := client.Get(server.DriveEndpoint() + "files?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var body struct {
+			Files []struct {
+				Name string `json:"name"`
+			} `json:"files"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		for _, f := range body.Files {
+			names = append(names, f.Name)
+		}
+		return names, resp.StatusCode
+	}
+	spreadsheets := "q=" + url.QueryEscape("mimeType = 'application/vnd.google-apps.spreadsheet'")
+	if names, _ := list(spreadsheets); !reflect.DeepEqual(names, []string{"in folder"}) {
+		t.Fatalf("shared drive files listed by default: %v", names)
+	}
+	if names, _ := list(spreadsheets + "&supportsAllDrives=true&includeItemsFromAllDrives=true"); len(names) != 2 {
+		t.Fatalf("expected files of every drive, got %v", names)
+	}
+	if names, _ := list(spreadsheets + "&supportsAllDrives=true&includeItemsFromAllDrives=true&corpora=drive&driveId=" + driveID); !reflect.DeepEqual(names, []string{"in shared drive"}) {
+		t.Fatalf("unexpected files of the shared drive %v", names)
+	}
+	if _, status := list("includeItemsFromAllDrives=true"); status != http.StatusForbidden {
+		t.Fatalf("expected 403 without supportsAllDrives, got %d", status)
+	}
+	inFolder := "q=" + url.QueryEscape("'"+folderID+"' in parents")
+	if names, _ := list(inFolder); !reflect.DeepEqual(names, []string{"in folder"}) {
+		t.Fatalf("unexpected files in folder %v", names)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, server.DriveEndpoint()+"files/"+created["id"].(string), nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("shared drive file deleted without supportsAllDrives: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,615 @@
+package gsheettest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultRowCount    int64 = 1000
+	defaultColumnCount int64 = 26
+)
+
+// spreadsheet Cells and properties of a spreadsheet
+type spreadsheet struct {
+	id          string
+	title       string
+	locale      string
+	timeZone    string
+	autoRecalc  string
+	sheets      []*sheet
+	nextSheetID int64
+}
+
+// sheet A grid of cells. cells[row][col] is string, float64, bool or nil(empty).
+type sheet struct {
+	id          int64
+	title       string
+	rowCount    int64
+	columnCount int64
+	cells       [][]interface{}
+}
+
+func (ss *spreadsheet) clone() *spreadsheet {
+	copied := *ss
+	copied.sheets = make([]*sheet, len(ss.sheets))
+	for i, sh := range ss.sheets {
+		copiedSheet := *sh
+		copiedSheet.cells = make([][]interface{}, len(sh.cells))
+		for r := range sh.cells {
+			copiedSheet.cells[r] = append([]interface{}(nil), sh.cells[r]...)
+		}
+		copied.sheets[i] = &copiedSheet
+	}
+	return &copied
+}
+
+func (ss *spreadsheet) sheetByID(id int64) (*sheet, bool) {
+	for _, sh := range ss.sheets {
+		if sh.id == id {
+			return sh, true
+		}
+	}
+	return nil, false
+}
+
+func (ss *spreadsheet) sheetByTitle(title string) (*sheet, bool) {
+	for _, sh := range ss.sheets {
+		if sh.title == title {
+			return sh, true
+		}
+	}
+	return nil, false
+}
+
+func (ss *spreadsheet) addSheet(title string, id *int64, rowCount, columnCount int64) (*sheet, error) {
+	if len(title) == 0 {
+		title = fmt.Sprintf("Sheet%d", len(ss.sheets)+1)
+	}
+	if _, ok := ss.sheetByTitle(title); ok {
+		return nil, fmt.Errorf("A sheet with the name \"%s\" already exists. Please enter another name.", title)
+	}
+	sheetID := ss.nextSheetID
+	if id != nil {
+		if _, ok := ss.sheetByID(*id); ok {
+			return nil, fmt.Errorf("Sheet with id %d already exists.", *id)
+		}
+		sheetID = *id
+	}
+	if sheetID >= ss.nextSheetID {
+		ss.nextSheetID = sheetID + 1
+	}
+	if rowCount <= 0 {
+		rowCount = defaultRowCount
+	}
+	if columnCount <= 0 {
+		columnCount = defaultColumnCount
+	}
+	sh := &sheet{
+		id:          sheetID,
+		title:       title,
+		rowCount:    rowCount,
+		columnCount: columnCount,
+	}
+	ss.sheets = append(ss.sheets, sh)
+	return sh, nil
+}
+
+// cell Value at row, col or nil
+func (sh *sheet) cell(row, col int64) interface{} {
+	if row >= int64(len(sh.cells)) || col >= int64(len(sh.cells[row])) {
+		return nil
+	}
+	return sh.cells[row][col]
+}
+
+// setCell Stores `value` at row, col. nil clears the cell.
+func (sh *sheet) setCell(row, col int64, value interface{}) {
+	for int64(len(sh.cells)) <= row {
+		sh.cells = append(sh.cells, nil)
+	}
+	for int64(len(sh.cells[row])) <= col {
+		sh.cells[row] = append(sh.cells[row], nil)
+	}
+	sh.cells[row][col] = value
+}
+
+// serveSheets Routes /v4/spreadsheets...
+func (s *Server) serveSheets(w http.ResponseWriter, r *http.Request, segments []string) {
+	if len(segments) == 1 {
+		if segments[0] == "spreadsheets" && r.Method == http.MethodPost {
+			s.createSpreadsheet(w, r)
+			return
+		}
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Unknown method "+r.URL.Path)
+		return
+	}
+
+	idSegment := segments[1]
+	method := ""
+	if i := strings.LastIndex(idSegment, ":"); i >= 0 {
+		idSegment, method = idSegment[:i], idSegment[i+1:]
+	}
+	id, _ := url.PathUnescape(idSegment)
+	f, ok := s.spreadsheetFile(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Requested entity was not found.")
+		return
+	}
+
+	switch {
+	case len(segments) == 2 && method == "" && r.Method == http.MethodGet:
+		s.getSpreadsheet(w, r, f)
+	case len(segments) == 2 && method == "batchUpdate" && r.Method == http.MethodPost:
+		s.batchUpdate(w, r, f)
+	case len(segments) == 3 && segments[2] == "values:batchUpdate" && r.Method == http.MethodPost:
+		s.valuesBatchUpdate(w, r, f)
+	case len(segments) == 4 && segments[2] == "values":
+		rangeSegment := segments[3]
+		valuesMethod := ""
+		for _, suffix := range []string{":clear", ":append"} {
+			if strings.HasSuffix(rangeSegment, suffix) {
+				rangeSegment = strings.TrimSuffix(rangeSegment, suffix)
+				valuesMethod = suffix[1:]
+			}
+		}
+		a1, err := url.PathUnescape(rangeSegment)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+			return
+		}
+		switch {
+		case valuesMethod == "" && r.Method == http.MethodGet:
+			s.valuesGet(w, r, f, a1)
+		case valuesMethod == "clear" && r.Method == http.MethodPost:
+			s.valuesClear(w, r, f, a1)
+		case valuesMethod == "append" && r.Method == http.MethodPost:
+			s.valuesAppend(w, r, f, a1)
+		default:
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "Unknown method "+r.URL.Path)
+		}
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Unknown method "+r.URL.Path)
+	}
+}
+
+type spreadsheetPropertiesInput struct {
+	Title      *string `json:"title"`
+	Locale     *string `json:"locale"`
+	TimeZone   *string `json:"timeZone"`
+	AutoRecalc *string `json:"autoRecalc"`
+}
+
+type sheetPropertiesInput struct {
+	SheetID        *int64 `json:"sheetId"`
+	Title          string `json:"title"`
+	GridProperties *struct {
+		RowCount    int64 `json:"rowCount"`
+		ColumnCount int64 `json:"columnCount"`
+	} `json:"gridProperties"`
+}
+
+func (p sheetPropertiesInput) gridSize() (int64, int64) {
+	if p.GridProperties == nil {
+		return 0, 0
+	}
+	return p.GridProperties.RowCount, p.GridProperties.ColumnCount
+}
+
+// createSpreadsheet POST /v4/spreadsheets
+func (s *Server) createSpreadsheet(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Properties *spreadsheetPropertiesInput `json:"properties"`
+		Sheets     []struct {
+			Properties sheetPropertiesInput `json:"properties"`
+		} `json:"sheets"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+
+	ss := newSpreadsheet("Untitled spreadsheet")
+	if p := body.Properties; p != nil {
+		applySpreadsheetProperties(ss, p)
+	}
+	for _, sh := range body.Sheets {
+		rows, cols := sh.Properties.gridSize()
+		if _, err := ss.addSheet(sh.Properties.Title, sh.Properties.SheetID, rows, cols); err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+			return
+		}
+	}
+	if len(ss.sheets) == 0 {
+		ss.addSheet("Sheet1", nil, 0, 0)
+	}
+
+	f, _ := s.newFile(ss.title, spreadsheetMimeType, RootFolderID)
+	ss.id = f.id
+	f.spreadsheet = ss
+
+	writeJSON(w, r, s.spreadsheetJSON(f, false))
+}
+
+// newSpreadsheet Spreadsheet with the default properties of Google Sheets, without sheets
+func newSpreadsheet(title string) *spreadsheet {
+	return &spreadsheet{
+		title:      title,
+		locale:     "en_US",
+		timeZone:   "Etc/GMT",
+		autoRecalc: "ON_CHANGE",
+	}
+}
+
+func applySpreadsheetProperties(ss *spreadsheet, p *spreadsheetPropertiesInput) {
+	if p.Title != nil {
+		ss.title = *p.Title
+	}
+	if p.Locale != nil {
+		ss.locale = *p.Locale
+	}
+	if p.TimeZone != nil {
+		ss.timeZone = *p.TimeZone
+	}
+	if p.AutoRecalc != nil {
+		ss.autoRecalc = *p.AutoRecalc
+	}
+}
+
+// getSpreadsheet GET /v4/spreadsheets/{id}
+func (s *Server) getSpreadsheet(w http.ResponseWriter, r *http.Request, f *file) {
+	includeGridData, _ := strconv.ParseBool(r.URL.Query().Get("includeGridData"))
+	writeJSON(w, r, s.spreadsheetJSON(f, includeGridData))
+}
+
+// spreadsheetJSON Spreadsheet resource of `f`
+func (s *Server) spreadsheetJSON(f *file, includeGridData bool) map[string]interface{} {
+	ss := f.spreadsheet
+	sheetsJSON := make([]interface{}, len(ss.sheets))
+	for i, sh := range ss.sheets {
+		sheetJSON := map[string]interface{}{
+			"properties": map[string]interface{}{
+				"sheetId":   sh.id,
+				"title":     sh.title,
+				"index":     i,
+				"sheetType": "GRID",
+				"gridProperties": map[string]interface{}{
+					"rowCount":    sh.rowCount,
+					"columnCount": sh.columnCount,
+				},
+			},
+		}
+		if includeGridData {
+			sheetJSON["data"] = []interface{}{gridDataJSON(sh)}
+		}
+		sheetsJSON[i] = sheetJSON
+	}
+	return map[string]interface{}{
+		"spreadsheetId": ss.id,
+		"properties": map[string]interface{}{
+			"title":      ss.title,
+			"locale":     ss.locale,
+			"autoRecalc": ss.autoRecalc,
+			"timeZone":   ss.timeZone,
+		},
+		"sheets":         sheetsJSON,
+		"spreadsheetUrl": fmt.Sprintf("%s/spreadsheets/d/%s/edit", s.URL, ss.id),
+	}
+}
+
+func gridDataJSON(sh *sheet) map[string]interface{} {
+	rows := make([]interface{}, len(sh.cells))
+	for r, row := range sh.cells {
+		values := make([]interface{}, len(row))
+		for c, value := range row {
+			values[c] = cellDataJSON(value)
+		}
+		rows[r] = map[string]interface{}{"values": values}
+	}
+	return map[string]interface{}{"rowData": rows}
+}
+
+func cellDataJSON(value interface{}) map[string]interface{} {
+	if value == nil {
+		return map[string]interface{}{}
+	}
+	extended := extendedValueJSON(value)
+	return map[string]interface{}{
+		"userEnteredValue": extended,
+		"effectiveValue":   extended,
+		"formattedValue":   formatValue(value),
+	}
+}
+
+func extendedValueJSON(value interface{}) map[string]interface{} {
+	switch v := value.(type) {
+	case bool:
+		return map[string]interface{}{"boolValue": v}
+	case float64:
+		return map[string]interface{}{"numberValue": v}
+	default:
+		return map[string]interface{}{"stringValue": fmt.Sprint(v)}
+	}
+}
+
+// formatValue Value as shown in the sheet
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+type gridRangeInput struct {
+	SheetID          int64  `json:"sheetId"`
+	StartRowIndex    int64  `json:"startRowIndex"`
+	EndRowIndex      *int64 `json:"endRowIndex"`
+	StartColumnIndex int64  `json:"startColumnIndex"`
+	EndColumnIndex   *int64 `json:"endColumnIndex"`
+}
+
+type extendedValueInput struct {
+	StringValue  *string  `json:"stringValue"`
+	NumberValue  *float64 `json:"numberValue"`
+	BoolValue    *bool    `json:"boolValue"`
+	FormulaValue *string  `json:"formulaValue"`
+}
+
+func (v *extendedValueInput) value() interface{} {
+	switch {
+	case v == nil:
+		return nil
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.NumberValue != nil:
+		return *v.NumberValue
+	case v.BoolValue != nil:
+		return *v.BoolValue
+	case v.FormulaValue != nil:
+		return *v.FormulaValue
+	}
+	return nil
+}
+
+type updateCellsInput struct {
+	Range *gridRangeInput `json:"range"`
+	Start *struct {
+		SheetID     int64 `json:"sheetId"`
+		RowIndex    int64 `json:"rowIndex"`
+		ColumnIndex int64 `json:"columnIndex"`
+	} `json:"start"`
+	Rows []struct {
+		Values []struct {
+			UserEnteredValue *extendedValueInput `json:"userEnteredValue"`
+		} `json:"values"`
+	} `json:"rows"`
+	Fields string `json:"fields"`
+}
+
+// batchUpdate POST /v4/spreadsheets/{id}:batchUpdate
+// Requests are applied atomically: if one fails, nothing changes.
+func (s *Server) batchUpdate(w http.ResponseWriter, r *http.Request, f *file) {
+	var body struct {
+		Requests                     []map[string]json.RawMessage `json:"requests"`
+		IncludeSpreadsheetInResponse bool                         `json:"includeSpreadsheetInResponse"`
+		ResponseIncludeGridData      bool                         `json:"responseIncludeGridData"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+
+	working := f.spreadsheet.clone()
+	replies := make([]interface{}, len(body.Requests))
+	for i, request := range body.Requests {
+		if len(request) != 1 {
+			writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", fmt.Sprintf("Invalid requests[%d]: exactly one kind of request must be set", i))
+			return
+		}
+		for kind, raw := range request {
+			reply, err := applyRequest(working, kind, raw)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", fmt.Sprintf("Invalid requests[%d].%s: %v", i, kind, err))
+				return
+			}
+			replies[i] = reply
+		}
+	}
+	f.spreadsheet = working
+	f.name = working.title
+	s.touch(f)
+
+	response := map[string]interface{}{
+		"spreadsheetId": working.id,
+		"replies":       replies,
+	}
+	if body.IncludeSpreadsheetInResponse {
+		response["updatedSpreadsheet"] = s.spreadsheetJSON(f, body.ResponseIncludeGridData)
+	}
+	writeJSON(w, r, response)
+}
+
+// applyRequest Applies a single batchUpdate request to `ss`
+func applyRequest(ss *spreadsheet, kind string, raw json.RawMessage) (interface{}, error) {
+	switch kind {
+	case "addSheet":
+		var req struct {
+			Properties sheetPropertiesInput `json:"properties"`
+		}
+		if err := json.Unmarshal(raw, &req); err != nil {
+			return nil, err
+		}
+		rows, cols := req.Properties.gridSize()
+		sh, err := ss.addSheet(req.Properties.Title, req.Properties.SheetID, rows, cols)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"addSheet": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"sheetId":   sh.id,
+					"title":     sh.title,
+					"index":     len(ss.sheets) - 1,
+					"sheetType": "GRID",
+					"gridProperties": map[string]interface{}{
+						"rowCount":    sh.rowCount,
+						"columnCount": sh.columnCount,
+					},
+				},
+			},
+		}, nil
+
+	case "deleteSheet":
+		var req struct {
+			SheetID int64 `json:"sheetId"`
+		}
+		if err := json.Unmarshal(raw, &req); err != nil {
+			return nil, err
+		}
+		for i, sh := range ss.sheets {
+			if sh.id == req.SheetID {
+				if len(ss.sheets) == 1 {
+					return nil, fmt.Errorf("You can't remove all the sheets in a document.")
+				}
+				ss.sheets = append(ss.sheets[:i], ss.sheets[i+1:]...)
+				return map[string]interface{}{}, nil
+			}
+		}
+		return nil, fmt.Errorf("No grid with id: %d", req.SheetID)
+
+	case "updateCells":
+		var req updateCellsInput
+		if err := json.Unmarshal(raw, &req); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{}, updateCells(ss, &req)
+
+	case "updateSpreadsheetProperties":
+		var req struct {
+			Properties spreadsheetPropertiesInput `json:"properties"`
+			Fields     string                     `json:"fields"`
+		}
+		if err := json.Unmarshal(raw, &req); err != nil {
+			return nil, err
+		}
+		if len(req.Fields) == 0 {
+			return nil, fmt.Errorf("fields is required")
+		}
+		applySpreadsheetProperties(ss, &req.Properties)
+		return map[string]interface{}{}, nil
+
+	case "updateSheetProperties":
+		var req struct {
+			Properties sheetPropertiesInput `json:"properties"`
+			Fields     string               `json:"fields"`
+		}
+		if err := json.Unmarshal(raw, &req); err != nil {
+			return nil, err
+		}
+		if req.Properties.SheetID == nil {
+			return nil, fmt.Errorf("properties.sheetId is required")
+		}
+		sh, ok := ss.sheetByID(*req.Properties.SheetID)
+		if !ok {
+			return nil, fmt.Errorf("No grid with id: %d", *req.Properties.SheetID)
+		}
+		if len(req.Properties.Title) > 0 {
+			if other, ok := ss.sheetByTitle(req.Properties.Title); ok && other != sh {
+				return nil, fmt.Errorf("A sheet with the name \"%s\" already exists. Please enter another name.", req.Properties.Title)
+			}
+			sh.title = req.Properties.Title
+		}
+		if rows, cols := req.Properties.gridSize(); rows > 0 || cols > 0 {
+			if rows > 0 {
+				sh.rowCount = rows
+			}
+			if cols > 0 {
+				sh.columnCount = cols
+			}
+		}
+		return map[string]interface{}{}, nil
+	}
+	return nil, fmt.Errorf("unsupported request kind %q", kind)
+}
+
+// updateCells Writes userEnteredValue of `req.Rows`.
+// With a range, cells of the range not covered by rows are cleared.
+func updateCells(ss *spreadsheet, req *updateCellsInput) error {
+	if len(req.Fields) == 0 {
+		return fmt.Errorf("fields is required")
+	}
+	if req.Fields != "*" && !strings.Contains(req.Fields, "userEnteredValue") {
+		return nil
+	}
+
+	var sh *sheet
+	var startRow, startCol int64
+	endRow, endCol := int64(-1), int64(-1)
+	switch {
+	case req.Range != nil:
+		var ok bool
+		if sh, ok = ss.sheetByID(req.Range.SheetID); !ok {
+			return fmt.Errorf("No grid with id: %d", req.Range.SheetID)
+		}
+		startRow, startCol = req.Range.StartRowIndex, req.Range.StartColumnIndex
+		if req.Range.EndRowIndex != nil {
+			endRow = *req.Range.EndRowIndex
+		}
+		if req.Range.EndColumnIndex != nil {
+			endCol = *req.Range.EndColumnIndex
+		}
+	case req.Start != nil:
+		var ok bool
+		if sh, ok = ss.sheetByID(req.Start.SheetID); !ok {
+			return fmt.Errorf("No grid with id: %d", req.Start.SheetID)
+		}
+		startRow, startCol = req.Start.RowIndex, req.Start.ColumnIndex
+	default:
+		return fmt.Errorf("range or start is required")
+	}
+
+	lastRow := startRow + int64(len(req.Rows))
+	lastCol := startCol
+	for _, row := range req.Rows {
+		if c := startCol + int64(len(row.Values)); c > lastCol {
+			lastCol = c
+		}
+	}
+	if endRow >= 0 && lastRow < endRow {
+		lastRow = endRow
+	}
+	if endCol >= 0 && lastCol < endCol {
+		lastCol = endCol
+	}
+	if lastRow > sh.rowCount || lastCol > sh.columnCount {
+		return fmt.Errorf("Range (%s) exceeds grid limits. Max rows: %d, max columns: %d",
+			a1Range(sh.title, startRow, startCol, lastRow, lastCol), sh.rowCount, sh.columnCount)
+	}
+
+	for r := startRow; r < lastRow; r++ {
+		for c := startCol; c < lastCol; c++ {
+			var value interface{}
+			i, j := r-startRow, c-startCol
+			if i < int64(len(req.Rows)) && j < int64(len(req.Rows[i].Values)) {
+				value = req.Rows[i].Values[j].UserEnteredValue.value()
+			} else if req.Range == nil {
+				continue
+			}
+			sh.setCell(r, c, value)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,510 @@
+package gsheettest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// gridRange Range of a sheet. endRow, endCol are not included, -1 means unbounded.
+type gridRange struct {
+	sheet                              *sheet
+	startRow, startCol, endRow, endCol int64
+	// single Range names a single cell, e.g. Sheet1!A3
+	single bool
+}
+
+var cellRefPattern = regexp.MustCompile(`^([A-Za-z]*)([0-9]*)$`)
+
+// parseA1 Parses A1 notation: Sheet1!A1:D4, 'My Sheet'!A:D, Sheet1!1:3, Sheet1, A1:B2
+func parseA1(ss *spreadsheet, a1 string) (gridRange, error) {
+	invalid := fmt.Errorf("Unable to parse range: %s", a1)
+
+	title, cells := "", a1
+	if i := strings.LastIndex(a1, "!"); i >= 0 {
+		title, cells = a1[:i], a1[i+1:]
+	} else if _, ok := ss.sheetByTitle(strings.Trim(a1, "'")); ok {
+		title, cells = a1, ""
+	}
+	if strings.HasPrefix(title, "'") && strings.HasSuffix(title, "'") && len(title) >= 2 {
+		title = strings.Replace(title[1:len(title)-1], "''", "'", -1)
+	}
+
+	var sh *sheet
+	if len(title) == 0 {
+		if len(ss.sheets) == 0 {
+			return gridRange{}, invalid
+		}
+		sh = ss.sheets[0]
+	} else {
+		var ok bool
+		if sh, ok = ss.sheetByTitle(title); !ok {
+			return gridRange{}, invalid
+		}
+	}
+
+	g := gridRange{sheet: sh, endRow: -1, endCol: -1}
+	if len(cells) == 0 {
+		return g, nil
+	}
+
+	parts := strings.Split(cells, ":")
+	if len(parts) > 2 {
+		return gridRange{}, invalid
+	}
+	startCol, startRow, ok := parseCellRef(parts[0])
+	if !ok {
+		return gridRange{}, invalid
+	}
+	if len(parts) == 1 {
+		if startCol < 0 || startRow < 0 {
+			return gridRange{}, invalid
+		}
+		g.startRow, g.startCol = startRow, startCol
+		g.endRow, g.endCol = startRow+1, startCol+1
+		g.single = true
+		return g, nil
+	}
+
+	endCol, endRow, ok := parseCellRef(parts[1])
+	if !ok {
+		return gridRange{}, invalid
+	}
+	if startCol >= 0 {
+		g.startCol = startCol
+	}
+	if startRow >= 0 {
+		g.startRow = startRow
+	}
+	if endCol >= 0 {
+		g.endCol = endCol + 1
+	}
+	if endRow >= 0 {
+		g.endRow = endRow + 1
+	}
+	if (startCol < 0) != (endCol < 0) && startRow < 0 {
+		return gridRange{}, invalid
+	}
+	if g.endRow >= 0 && g.endRow <= g.startRow || g.endCol >= 0 && g.endCol <= g.startCol {
+		return gridRange{}, invalid
+	}
+	return g, nil
+}
+
+// parseCellRef Zero based column and row of "B3". Missing parts are -1.
+func parseCellRef(ref string) (col, row int64, ok bool) {
+	matched := cellRefPattern.FindStringSubmatch(ref)
+	if matched == nil || len(matched[1])+len(matched[2]) == 0 {
+		return 0, 0, false
+	}
+	col, row = -1, -1
+	if letters := strings.ToUpper(matched[1]); len(letters) > 0 {
+		col = 0
+		for _, l := range letters {
+			col = col*26 + int64(l-'A'+1)
+		}
+		col--
+	}
+	if len(matched[2]) > 0 {
+		n, err := strconv.ParseInt(matched[2], 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		row = n - 1
+	}
+	return col, row, true
+}
+
+// bounded Range clipped to the grid
+func (g gridRange) bounded() gridRange {
+	if g.endRow < 0 {
+		g.endRow = g.sheet.rowCount
+	}
+	if g.endCol < 0 {
+		g.endCol = g.sheet.columnCount
+	}
+	return g
+}
+
+// checkLimits Error if the range exceeds the grid
+func (g gridRange) checkLimits(a1 string) error {
+	b := g.bounded()
+	if b.endRow > g.sheet.rowCount || b.endCol > g.sheet.columnCount {
+		return fmt.Errorf("Range (%s) exceeds grid limits. Max rows: %d, max columns: %d", a1, g.sheet.rowCount, g.sheet.columnCount)
+	}
+	return nil
+}
+
+func (g gridRange) String() string {
+	b := g.bounded()
+	return a1Range(g.sheet.title, b.startRow, b.startCol, b.endRow, b.endCol)
+}
+
+// a1Range A1 notation of a bounded range
+func a1Range(title string, startRow, startCol, endRow, endCol int64) string {
+	return fmt.Sprintf("%s!%s%d:%s%d", quoteSheetTitle(title),
+		columnLetters(startCol), startRow+1, columnLetters(endCol-1), endRow)
+}
+
+func quoteSheetTitle(title string) string {
+	for _, r := range title {
+		if !(r == '_' || '0' <= r && r <= '9' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z') {
+			return "'" + strings.Replace(title, "'", "''", -1) + "'"
+		}
+	}
+	return title
+}
+
+// columnLetters Letters of zero based column, 0 <-> A
+func columnLetters(col int64) string {
+	var letters []byte
+	for col++; col > 0; col /= 26 {
+		col--
+		letters = append([]byte{byte('A' + col%26)}, letters...)
+	}
+	return string(letters)
+}
+
+// valuesGet GET /v4/spreadsheets/{id}/values/{range}
+func (s *Server) valuesGet(w http.ResponseWriter, r *http.Request, f *file, a1 string) {
+	g, err := parseA1(f.spreadsheet, a1)
+	if err == nil {
+		err = g.checkLimits(a1)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	render := query.Get("valueRenderOption")
+	columns := query.Get("majorDimension") == "COLUMNS"
+
+	b := g.bounded()
+	var values [][]interface{}
+	for row := b.startRow; row < b.endRow; row++ {
+		var rowValues []interface{}
+		for col := b.startCol; col < b.endCol; col++ {
+			rowValues = append(rowValues, renderValue(b.sheet.cell(row, col), render))
+		}
+		values = append(values, rowValues)
+	}
+	if columns {
+		values = transpose(values)
+	}
+	values = trimValues(values)
+
+	body := map[string]interface{}{
+		"range":          g.String(),
+		"majorDimension": "ROWS",
+	}
+	if columns {
+		body["majorDimension"] = "COLUMNS"
+	}
+	if len(values) > 0 {
+		body["values"] = values
+	}
+	writeJSON(w, r, body)
+}
+
+// renderValue Cell value as returned by values.get
+func renderValue(value interface{}, render string) interface{} {
+	if value == nil {
+		return ""
+	}
+	if render == "UNFORMATTED_VALUE" || render == "FORMULA" {
+		return value
+	}
+	return formatValue(value)
+}
+
+func transpose(values [][]interface{}) [][]interface{} {
+	if len(values) == 0 {
+		return nil
+	}
+	transposed := make([][]interface{}, len(values[0]))
+	for c := range transposed {
+		transposed[c] = make([]interface{}, len(values))
+		for r := range values {
+			transposed[c][r] = values[r][c]
+		}
+	}
+	return transposed
+}
+
+// trimValues Drops trailing empty cells and rows like the real API
+func trimValues(values [][]interface{}) [][]interface{} {
+	for i := range values {
+		row := values[i]
+		for len(row) > 0 && row[len(row)-1] == "" {
+			row = row[:len(row)-1]
+		}
+		values[i] = row
+	}
+	for len(values) > 0 && len(values[len(values)-1]) == 0 {
+		values = values[:len(values)-1]
+	}
+	return values
+}
+
+type valueRangeInput struct {
+	Range          string          `json:"range"`
+	MajorDimension string          `json:"majorDimension"`
+	Values         [][]interface{} `json:"values"`
+}
+
+// valueWrite Values to be written at a range
+type valueWrite struct {
+	g      gridRange
+	values [][]interface{}
+}
+
+// prepareWrite Validates writing `input` and returns cells to update
+func prepareWrite(ss *spreadsheet, input valueRangeInput) (valueWrite, error) {
+	g, err := parseA1(ss, input.Range)
+	if err != nil {
+		return valueWrite{}, err
+	}
+	if err := checkScalars(input.Values); err != nil {
+		return valueWrite{}, err
+	}
+	values := input.Values
+	if input.MajorDimension == "COLUMNS" {
+		values = transpose(values)
+	}
+
+	rows := int64(len(values))
+	var cols int64
+	for _, row := range values {
+		if int64(len(row)) > cols {
+			cols = int64(len(row))
+		}
+	}
+	endRow, endCol := g.startRow+rows, g.startCol+cols
+	if !g.single {
+		b := g.bounded()
+		if rows > 0 && endRow > b.endRow || cols > 0 && endCol > b.endCol {
+			return valueWrite{}, fmt.Errorf("Requested writing within range [%s], but tried writing to %s",
+				input.Range, a1Range(g.sheet.title, g.startRow, g.startCol, endRow, endCol))
+		}
+	}
+	if endRow > g.sheet.rowCount || endCol > g.sheet.columnCount {
+		return valueWrite{}, fmt.Errorf("Range (%s) exceeds grid limits. Max rows: %d, max columns: %d",
+			input.Range, g.sheet.rowCount, g.sheet.columnCount)
+	}
+	return valueWrite{g: g, values: values}, nil
+}
+
+// checkScalars Error if a value is not a string, number, bool or null, as the API rejects objects and arrays
+func checkScalars(values [][]interface{}) error {
+	for i, row := range values {
+		for j, v := range row {
+			switch v.(type) {
+			case nil, string, float64, bool:
+			default:
+				return fmt.Errorf("Invalid values[%d][%d]: %v", i, j, v)
+			}
+		}
+	}
+	return nil
+}
+
+// apply Writes values. nil(null) keeps the cell, "" clears it.
+func (vw valueWrite) apply(inputOption string) map[string]interface{} {
+	var updatedCells, updatedRows, updatedColumns int64
+	for i, row := range vw.values {
+		for j, raw := range row {
+			if raw == nil {
+				continue
+			}
+			vw.g.sheet.setCell(vw.g.startRow+int64(i), vw.g.startCol+int64(j), inputValue(raw, inputOption))
+			updatedCells++
+		}
+		if int64(len(row)) > updatedColumns {
+			updatedColumns = int64(len(row))
+		}
+		updatedRows++
+	}
+	updatedRange := vw.g.String()
+	if updatedRows > 0 && updatedColumns > 0 {
+		updatedRange = a1Range(vw.g.sheet.title, vw.g.startRow, vw.g.startCol, vw.g.startRow+updatedRows, vw.g.startCol+updatedColumns)
+	}
+	return map[string]interface{}{
+		"updatedRange":   updatedRange,
+		"updatedRows":    updatedRows,
+		"updatedColumns": updatedColumns,
+		"updatedCells":   updatedCells,
+	}
+}
+
+// inputValue Interprets a written value according to valueInputOption
+func inputValue(raw interface{}, inputOption string) interface{} {
+	str, ok := raw.(string)
+	if !ok {
+		return raw
+	}
+	if len(str) == 0 {
+		return nil
+	}
+	if inputOption != "USER_ENTERED" {
+		return str
+	}
+	if strings.HasPrefix(str, "'") {
+		return str[1:]
+	}
+	if b, err := strconv.ParseBool(str); err == nil && strings.EqualFold(str, strconv.FormatBool(b)) {
+		return b
+	}
+	if n, err := strconv.ParseFloat(str, 64); err == nil {
+		return n
+	}
+	return str
+}
+
+func validInputOption(option string) bool {
+	return option == "RAW" || option == "USER_ENTERED"
+}
+
+// valuesBatchUpdate POST /v4/spreadsheets/{id}/values:batchUpdate
+func (s *Server) valuesBatchUpdate(w http.ResponseWriter, r *http.Request, f *file) {
+	var body struct {
+		ValueInputOption        string            `json:"valueInputOption"`
+		Data                    []valueRangeInput `json:"data"`
+		IncludeValuesInResponse bool              `json:"includeValuesInResponse"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+	if !validInputOption(body.ValueInputOption) {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid valueInputOption: "+body.ValueInputOption)
+		return
+	}
+
+	writes := make([]valueWrite, len(body.Data))
+	for i, data := range body.Data {
+		vw, err := prepareWrite(f.spreadsheet, data)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", fmt.Sprintf("Invalid data[%d]: %v", i, err))
+			return
+		}
+		writes[i] = vw
+	}
+
+	var responses []interface{}
+	var totalCells, totalRows, totalColumns int64
+	for _, vw := range writes {
+		response := vw.apply(body.ValueInputOption)
+		response["spreadsheetId"] = f.id
+		if body.IncludeValuesInResponse {
+			response["updatedData"] = map[string]interface{}{
+				"range":          response["updatedRange"],
+				"majorDimension": "ROWS",
+				"values":         vw.values,
+			}
+		}
+		totalCells += response["updatedCells"].(int64)
+		totalRows += response["updatedRows"].(int64)
+		totalColumns += response["updatedColumns"].(int64)
+		responses = append(responses, response)
+	}
+	if len(writes) > 0 {
+		s.touch(f)
+	}
+
+	writeJSON(w, r, map[string]interface{}{
+		"spreadsheetId":       f.id,
+		"totalUpdatedSheets":  len(writes),
+		"totalUpdatedCells":   totalCells,
+		"totalUpdatedRows":    totalRows,
+		"totalUpdatedColumns": totalColumns,
+		"responses":           responses,
+	})
+}
+
+// valuesClear POST /v4/spreadsheets/{id}/values/{range}:clear
+func (s *Server) valuesClear(w http.ResponseWriter, r *http.Request, f *file, a1 string) {
+	g, err := parseA1(f.spreadsheet, a1)
+	if err == nil {
+		err = g.checkLimits(a1)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+	b := g.bounded()
+	for row := b.startRow; row < b.endRow && row < int64(len(b.sheet.cells)); row++ {
+		for col := b.startCol; col < b.endCol && col < int64(len(b.sheet.cells[row])); col++ {
+			b.sheet.cells[row][col] = nil
+		}
+	}
+	s.touch(f)
+	writeJSON(w, r, map[string]interface{}{
+		"spreadsheetId": f.id,
+		"clearedRange":  g.String(),
+	})
+}
+
+// valuesAppend POST /v4/spreadsheets/{id}/values/{range}:append
+// Values are written below the last non empty row of the range's columns.
+func (s *Server) valuesAppend(w http.ResponseWriter, r *http.Request, f *file, a1 string) {
+	query := r.URL.Query()
+	if !validInputOption(query.Get("valueInputOption")) {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid valueInputOption: "+query.Get("valueInputOption"))
+		return
+	}
+	var input valueRangeInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+	g, err := parseA1(f.spreadsheet, a1)
+	if err == nil {
+		// before growing the sheet
+		err = checkScalars(input.Values)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+
+	b := g.bounded()
+	if g.single {
+		b.endCol = g.sheet.columnCount
+	}
+	next := b.startRow
+	for row := b.startRow; row < int64(len(b.sheet.cells)); row++ {
+		for col := b.startCol; col < b.endCol; col++ {
+			if b.sheet.cell(row, col) != nil {
+				next = row + 1
+				break
+			}
+		}
+	}
+
+	if grow := next + int64(len(input.Values)); grow > g.sheet.rowCount {
+		g.sheet.rowCount = grow
+	}
+	target := a1Range(g.sheet.title, next, b.startCol, next+1, b.startCol+1)
+	vw, err := prepareWrite(f.spreadsheet, valueRangeInput{
+		Range:          target[:strings.LastIndex(target, ":")],
+		MajorDimension: input.MajorDimension,
+		Values:         input.Values,
+	})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+	updates := vw.apply(query.Get("valueInputOption"))
+	updates["spreadsheetId"] = f.id
+	s.touch(f)
+
+	writeJSON(w, r, map[string]interface{}{
+		"spreadsheetId": f.id,
+		"tableRange":    g.String(),
+		"updates":       updates,
+	})
+}
@@ -36,6 +36,9 @@ func (index *tableIndex) build(values [][]interface{}, metadata *TableScheme) {
 
 	for i, v := range values {
 		uniqueColumns := metadata.columnsToIndices(metadata.Constraints.uniqueColumns)
+		if metadata.hasNullKey(v, uniqueColumns) {
+			continue
+		}
 		uniqueHash := index.hashcode(v, uniqueColumns...)
 		bucket, ok := index.uniqueIndex[uniqueHash]
 		if ok {
@@ -52,6 +55,7 @@ func (index *tableIndex) build(values [][]interface{}, metadata *TableScheme) {
 var trueOrFalse = map[bool]string{true: "TRUE", false: "FALSE"}
 
 // value: single struct splitted to column values
+// Columns past the end of `value` are blank, as Sheets does not return trailing blank cells.
 func (index *tableIndex) hashcode(value []interface{}, columnIndices ...int64) string {
 	reflectedValue := reflect.ValueOf(value)
 	testValue := ""
@@ -60,6 +64,10 @@ func (index *tableIndex) hashcode(value []interface{}, columnIndices ...int64) s
 	})
 
 	for _, idx := range columnIndices {
+		if int(idx) >= len(value) {
+			testValue += fmt.Sprintf("%v", idx)
+			continue
+		}
 		field := reflectedValue.Index(int(idx))
 		// 특별 예외: bool은 대문자로 변환
 		var msg string
@@ -0,0 +1,53 @@
+package gosheet
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"unicode/utf8"
+)
+
+// jsonTypeName Type name of JSON columns in the metadata row
+const jsonTypeName = "json"
+
+// maxCellLength Characters a cell of Sheets can hold
+const maxCellLength = 50000
+
+// isJSONKind Fields of kind `kind` are stored as JSON
+func isJSONKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.Interface:
+		return true
+	}
+	return false
+}
+
+// marshalCell JSON text of `value` written to a cell.
+// Returns ErrValueTooLarge if it does not fit in a cell.
+func marshalCell(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	if n := utf8.RuneCount(data); n > maxCellLength {
+		return "", fmt.Errorf("%w: %d characters of JSON", ErrValueTooLarge, n)
+	}
+	return string(data), nil
+}
+
+// unmarshalCell Sets `v` to the JSON of a cell. Empty cells leave `v` zero.
+func unmarshalCell(v reflect.Value, cell interface{}) error {
+	text, ok := cell.(string)
+	if !ok {
+		// numbers and booleans entered by hand
+		data, err := json.Marshal(cell)
+		if err != nil {
+			return err
+		}
+		text = string(data)
+	}
+	if len(text) == 0 {
+		return nil
+	}
+	return json.Unmarshal([]byte(text), v.Addr().Interface())
+}
@@ -0,0 +1,82 @@
+package gosheet
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type TestStructDocument struct {
+	Title string
+	Tags  []string
+	Attrs map[string]string `gsheet:",omitempty"`
+	Home  TestAddress       `gsheet:",json"`
+	Extra interface{}
+}
+
+func TestJSONColumns(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
+
+	table, err := db.CreateTable(TestStructDocument{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheme := table.header()
+	if !reflect.DeepEqual(scheme.Columns, []string{"Title", "Tags", "Attrs", "Home", "Extra"}) {
+		t.Fatalf("unexpected columns %v", scheme.Columns)
+	}
+	if !reflect.DeepEqual(scheme.typeNames, []string{"string", "json", "json", "json", "json"}) {
+		t.Fatalf("unexpected types %v", scheme.typeNames)
+	}
+
+	documents := []TestStructDocument{
+		{
+			Title: "first",
+			Tags:  []string{"a", "b"},
+			Attrs: map[string]string{"lang": "ko"},
+			Home:  TestAddress{City: "Seoul", Zip: 4524},
+			Extra: 3.5,
+		},
+		{Title: "second"},
+	}
+	if err := table.UpsertIf([]interface{}{documents[0], documents[1]}, true); err != nil {
+		t.Fatal(err)
+	}
+	// rows of values are encoded too
+	if err := table.UpsertIf([]interface{}{[]interface{}{"third", []string{"c"}, nil, TestAddress{City: "X"}, nil}}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, _, err := table.Select(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rows[0][1], []interface{}{"a", "b"}) || !reflect.DeepEqual(rows[0][3], map[string]interface{}{"City": "Seoul", "Zip": 4524.0}) {
+		t.Fatalf("unexpected decoded row %v", rows[0])
+	}
+	if rows[1][1] != nil || rows[1][2] != nil {
+		t.Fatalf("expected null and empty cells to be nil, got %v", rows[1])
+	}
+
+	var selected []TestStructDocument
+	if err := table.SelectInto(&selected); err != nil {
+		t.Fatal(err)
+	}
+	if len(selected) != 3 || !reflect.DeepEqual(selected[:2], documents) || !reflect.DeepEqual(selected[2].Tags, []string{"c"}) {
+		t.Fatalf("expected %+v, got %+v", documents, selected)
+	}
+	if selected[2].Home != (TestAddress{City: "X"}) || selected[2].Attrs != nil {
+		t.Fatalf("unexpected row of values %+v", selected[2])
+	}
+
+	large := TestStructDocument{Title: "large", Tags: []string{strings.Repeat("x", maxCellLength)}}
+	if err := table.UpsertIf([]interface{}{large}, true); !errors.Is(err, ErrValueTooLarge) {
+		t.Fatalf("expected ErrValueTooLarge, got %v", err)
+	}
+	if rows := table.header().Rows; rows != 3 {
+		t.Fatalf("expected 3 rows after a rejected upsert, got %d", rows)
+	}
+}
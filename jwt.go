@@ -2,43 +2,65 @@ package gosheet
 
 import (
 	"context"
-	"io/ioutil"
+	"errors"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
+	"golang.org/x/oauth2/jwt"
 	"google.golang.org/api/drive/v2"
 	"google.golang.org/api/sheets/v4"
 )
 
 var scope = []string{drive.DriveScope, drive.DriveFileScope, sheets.SpreadsheetsScope}
 
-func createJWTToken(jsonPath string) *oauth2.Token {
-	cred, err := google.FindDefaultCredentials(context.Background(), scope...)
+// jwtConfigFromJSON JWT config of a service account json key, impersonating cfg.subject if given
+func jwtConfigFromJSON(jsonKey []byte, cfg *managerConfig) (*jwt.Config, error) {
+	jwtConfig, err := google.JWTConfigFromJSON(jsonKey, cfg.scopes...)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	jsonKey, err := ioutil.ReadFile(jsonPath)
+	jwtConfig.Subject = cfg.subject
+	return jwtConfig, nil
+}
+
+// tokenSourceFromJSON Token source of a service account json key
+func tokenSourceFromJSON(jsonKey []byte, cfg *managerConfig) (oauth2.TokenSource, error) {
+	jwtConfig, err := jwtConfigFromJSON(jsonKey, cfg)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	return jwtConfig.TokenSource(cfg.tokenContext(context.Background())), nil
+}
 
-	var tokenSource oauth2.TokenSource
-
-	if cred != nil && false {
-		tokenSource = cred.TokenSource
-	} else if len(jsonKey) > 0 {
-		cfg, err := google.JWTConfigFromJSON(jsonKey, drive.DriveScope, drive.DriveFileScope, sheets.SpreadsheetsScope)
-		if err != nil {
-			panic(err)
+// defaultTokenSource Token source of Application Default Credentials
+// https://cloud.google.com/docs/authentication/production
+// `ctx` bounds finding the credentials only, as the token source keeps refreshing tokens after it.
+func defaultTokenSource(ctx context.Context, cfg *managerConfig) (oauth2.TokenSource, error) {
+	type found struct {
+		cred *google.Credentials
+		err  error
+	}
+	done := make(chan found, 1)
+	go func() {
+		cred, err := google.FindDefaultCredentials(cfg.tokenContext(context.Background()), cfg.scopes...)
+		done <- found{cred, err}
+	}()
+	var cred *google.Credentials
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-done:
+		if result.err != nil {
+			return nil, result.err
 		}
-		tokenSource = cfg.TokenSource(context.Background())
-	} else {
-		panic("No way")
+		cred = result.cred
 	}
-
-	token, err := tokenSource.Token()
-	if err != nil {
-		panic(err)
+	if len(cfg.subject) == 0 {
+		return cred.TokenSource, nil
+	}
+	// impersonation needs the key of the service account
+	if len(cred.JSON) == 0 {
+		return nil, errors.New("gosheet: impersonation needs service account credentials")
 	}
-	return token
+	return tokenSourceFromJSON(cred.JSON, cfg)
 }
@@ -1,19 +1,51 @@
 package gosheet
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
 	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/drive/v2"
+	"google.golang.org/api/sheets/v4"
 )
 
-const jsonPath = "/Users/shp/Documents/projects/ticklemeta-203110-709122f3e3af.json"
+// liveCredentials Path of a service account key for tests against Google.
+// Skips the test if GOOGLE_APPLICATION_CREDENTIALS is not set.
+func liveCredentials(t testing.TB) string {
+	jsonPath := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if len(jsonPath) == 0 {
+		t.Skip("GOOGLE_APPLICATION_CREDENTIALS is not set")
+	}
+	return jsonPath
+}
 
 func TestJWT(t *testing.T) {
-	// envKey := "GOOGLE_APPLICATION_CREDENTIALS"
-	// jsonPath := os.Getenv(envKey)
-	token := createJWTToken(jsonPath)
+	jsonKey, err := ioutil.ReadFile(liveCredentials(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenSource, err := tokenSourceFromJSON(jsonKey, newManagerConfig(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := tokenSource.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
 	fmt.Printf("access token: %s\n", token.AccessToken)
 	fmt.Printf("expires at: %v\n", token.Expiry)
 
@@ -102,3 +134,156 @@ func testDriveAboutAPI(accessToken string) {
 		}
 	}
 }
+
+func TestTokenSourceManager(t *testing.T) {
+	if _, err := NewSheetManagerFromJSON([]byte("not a json key")); err == nil {
+		t.Fatal("expected error from invalid json key")
+	}
+
+	var calls int
+	tokenSource := tokenSourceFunc(func() (*oauth2.Token, error) {
+		calls++
+		return &oauth2.Token{AccessToken: "static", Expiry: time.Now().Add(time.Hour)}, nil
+	})
+	manager, err := NewSheetManagerFromTokenSource(tokenSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := make(http.Header)
+	for i := 0; i < 3; i++ {
+		if err := manager.authorize(header); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if header.Get("Authorization") != "Bearer static" {
+		t.Fatalf("unexpected authorization header %q", header.Get("Authorization"))
+	}
+	if calls != 1 {
+		t.Fatalf("valid token should be reused, fetched %d times", calls)
+	}
+}
+
+type tokenSourceFunc func() (*oauth2.Token, error)
+
+func (f tokenSourceFunc) Token() (*oauth2.Token, error) {
+	return f()
+}
+
+// newServiceAccountKey Service account json key whose token endpoint is `tokenURL`
+func newServiceAccountKey(t *testing.T, tokenURL string) []byte {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	jsonKey, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"client_email":   "robot@project.iam.gserviceaccount.com",
+		"private_key_id": "key-id",
+		"private_key":    string(keyPEM),
+		"token_uri":      tokenURL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jsonKey
+}
+
+func TestImpersonation(t *testing.T) {
+	var claims struct {
+		Subject string `json:"sub"`
+		Scope   string `json:"scope"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assertion := strings.Split(r.FormValue("assertion"), ".")
+		if len(assertion) != 3 {
+			http.Error(w, "bad assertion", http.StatusBadRequest)
+			return
+		}
+		payload, err := base64.RawURLEncoding.DecodeString(assertion[1])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.Unmarshal(payload, &claims)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"delegated","token_type":"Bearer","expires_in":3600}`)
+	}))
+	defer server.Close()
+
+	jsonKey := newServiceAccountKey(t, server.URL)
+	manager, err := NewSheetManagerFromJSON(jsonKey,
+		WithSubject("user@example.com"),
+		WithScopes(drive.DriveFileScope, sheets.SpreadsheetsScope))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "user@example.com" {
+		t.Fatalf("expected subject user@example.com, got %q", claims.Subject)
+	}
+	if claims.Scope != drive.DriveFileScope+" "+sheets.SpreadsheetsScope {
+		t.Fatalf("unexpected scope %q", claims.Scope)
+	}
+	header := make(http.Header)
+	if err := manager.authorize(header); err != nil {
+		t.Fatal(err)
+	}
+	if header.Get("Authorization") != "Bearer delegated" {
+		t.Fatalf("unexpected authorization header %q", header.Get("Authorization"))
+	}
+
+	if _, err := NewSheetManagerFromTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "static"}), WithSubject("user@example.com")); err == nil {
+		t.Fatal("token source cannot impersonate")
+	}
+}
+
+// redirectTransport Sends every request to `target` instead of its host
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (t redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	redirected := req.Clone(req.Context())
+	redirected.URL.Scheme, redirected.URL.Host = t.target.Scheme, t.target.Host
+	redirected.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(redirected)
+}
+
+func TestDefaultCredentialsOutliveContext(t *testing.T) {
+	var issued int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		issued++
+		w.Header().Set("Content-Type", "application/json")
+		// expires at once, so every request refreshes it
+		fmt.Fprint(w, `{"access_token":"default","token_type":"Bearer","expires_in":1}`)
+	}))
+	defer server.Close()
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyFile, err := ioutil.TempFile("", "gosheet-credentials-*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(keyFile.Name())
+	// user credentials refresh tokens with the context of the token source
+	fmt.Fprint(keyFile, `{"type":"authorized_user","client_id":"id","client_secret":"secret","refresh_token":"refresh"}`)
+	keyFile.Close()
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", keyFile.Name())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	manager, err := NewSheetManagerWithDefaultCredentials(ctx, WithHTTPClient(&http.Client{Transport: redirectTransport{target}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	header := make(http.Header)
+	if err := manager.authorize(header); err != nil {
+		t.Fatalf("token refresh after the context ended: %v", err)
+	}
+	if header.Get("Authorization") != "Bearer default" || issued < 2 {
+		t.Fatalf("expected a refreshed token, got %q after %d tokens", header.Get("Authorization"), issued)
+	}
+}
@@ -0,0 +1,64 @@
+package gosheet
+
+import (
+	"reflect"
+	"strings"
+)
+
+// nullablePrefix Prefix of the type names of nullable columns in the metadata row, e.g. "*int64".
+// NULL is a blank cell. Sheets cannot keep an empty string in a cell,
+// so a pointer to "" is NULL when read back.
+const nullablePrefix = "*"
+
+// isNullable Columns typed `typeName` are nullable
+func isNullable(typeName string) bool {
+	return strings.HasPrefix(typeName, nullablePrefix)
+}
+
+// columnKind Kind of columns typed `typeName` in the metadata row
+func columnKind(typeName string) (reflect.Kind, bool) {
+	kind, ok := primitiveStringToKind[strings.TrimPrefix(typeName, nullablePrefix)]
+	return kind, ok
+}
+
+// nullableColumnType Type name and kind of a pointer field typed `t`
+func nullableColumnType(t reflect.Type) (string, reflect.Kind, bool) {
+	elem := t.Elem()
+	if elem.Kind() == reflect.Ptr {
+		return "", t.Kind(), false
+	}
+	name, kind, ok := columnType(elem)
+	if !ok {
+		return "", t.Kind(), false
+	}
+	// JSON has null
+	if name == jsonTypeName {
+		return name, kind, true
+	}
+	return nullablePrefix + name, kind, true
+}
+
+// hasNullable The table has a nullable column
+func (metadata *TableScheme) hasNullable() bool {
+	for _, typeName := range metadata.typeNames {
+		if isNullable(typeName) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasNullKey `value` is NULL in one of the nullable columns at `columnIndices`.
+// Like SQL, rows with a NULL key never conflict with the unique constraint.
+func (metadata *TableScheme) hasNullKey(value []interface{}, columnIndices []int64) bool {
+	for _, idx := range columnIndices {
+		if int(idx) >= len(metadata.typeNames) || !isNullable(metadata.typeNames[idx]) {
+			continue
+		}
+		// trailing blank cells are not read
+		if int(idx) >= len(value) || value[idx] == nil || value[idx] == "" {
+			return true
+		}
+	}
+	return false
+}
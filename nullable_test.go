@@ -0,0 +1,231 @@
+package gosheet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type TestStructNullable struct {
+	Name    string
+	Score   *int64
+	Comment *string
+	Active  *bool
+	Until   *time.Time
+}
+
+func TestNullableColumns(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
+
+	table, err := db.CreateTable(TestStructNullable{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheme := table.header()
+	if !reflect.DeepEqual(scheme.typeNames, []string{"string", "*int64", "*string", "*bool", "*time"}) {
+		t.Fatalf("unexpected types %v", scheme.typeNames)
+	}
+	if !reflect.DeepEqual(scheme.Types, []reflect.Kind{reflect.String, reflect.Int64, reflect.String, reflect.Bool, reflect.Struct}) {
+		t.Fatalf("unexpected kinds %v", scheme.Types)
+	}
+
+	zero, comment, inactive := int64(0), "hello", false
+	until := time.Date(2024, 3, 1, 9, 0, 0, 0, db.location())
+	values := []TestStructNullable{
+		{Name: "set", Score: &zero, Comment: &comment, Active: &inactive, Until: &until},
+		{Name: "unset"},
+	}
+	if err := table.UpsertIf([]interface{}{values[0], values[1]}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, _, err := table.Select(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// rows are padded with nil for the trailing blank cells
+	if len(rows) != 2 || !reflect.DeepEqual(rows[1], []interface{}{"unset", nil, nil, nil, nil}) {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+	if rows[0][1] != "0" || rows[0][3] != "FALSE" {
+		t.Fatalf("expected set values, got %v", rows[0])
+	}
+
+	filtered, _, err := table.SelectAndFilter(map[int]Predicate{
+		1: func(v interface{}) bool { return v == nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filtered) != 1 || filtered[0][0] != "unset" {
+		t.Fatalf("unexpected filtered rows %v", filtered)
+	}
+
+	var selected []TestStructNullable
+	if err := table.SelectInto(&selected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(selected, values) {
+		t.Fatalf("expected %+v, got %+v", values, selected)
+	}
+
+	// overwriting a value with NULL clears the cell
+	if _, err := table.Delete(func(row []interface{}) bool { return row[0] == "set" }); err != nil {
+		t.Fatal(err)
+	}
+	selected = nil
+	if err := table.SelectInto(&selected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(selected, values[1:]) {
+		t.Fatalf("expected %+v, got %+v", values[1:], selected)
+	}
+}
+
+func TestUpsertIfDecodedConditions(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
+
+	table, err := db.CreateTable(TestStructNullable{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	score := int64(7)
+	until := time.Date(2024, 3, 1, 9, 0, 0, 0, db.location())
+	values := []interface{}{
+		TestStructNullable{Name: "set", Score: &score, Until: &until},
+		TestStructNullable{Name: "unset"},
+	}
+	// conditions see the values SelectAndFilter does
+	var seen []interface{}
+	record := map[int]Predicate{
+		4: func(v interface{}) bool {
+			seen = append(seen, v)
+			return true
+		},
+	}
+	err = table.UpsertIf(values, true, record, map[int]Predicate{
+		1: func(v interface{}) bool { return v == nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 2 || !until.Equal(seen[0].(time.Time)) || seen[1] != nil {
+		t.Fatalf("unexpected values given to the condition %v", seen)
+	}
+	rows, _, err := table.Select(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0][0] != "unset" {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+}
+
+type TestStructNullablePair struct {
+	A *int64
+	B *string
+}
+
+func TestNullableTrailingRow(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
+
+	table, err := db.CreateTable(TestStructNullablePair{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	one, two := int64(1), int64(2)
+	values := []TestStructNullablePair{{A: &one}, {A: &two}, {}}
+	if err := table.UpsertIf([]interface{}{values[0], values[1], values[2]}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// the last row is all NULL
+	rows, _, err := table.Select(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || !reflect.DeepEqual(rows[2], []interface{}{nil, nil}) {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+	filtered, _, err := table.SelectAndFilter(map[int]Predicate{
+		0: func(v interface{}) bool { return v == nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filtered) != 1 {
+		t.Fatalf("unexpected filtered rows %v", filtered)
+	}
+	var selected []TestStructNullablePair
+	if err := table.SelectInto(&selected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(selected, values) {
+		t.Fatalf("expected %+v, got %+v", values, selected)
+	}
+
+	deleted, err := table.Delete(func(row []interface{}) bool { return row[0] == "1" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(deleted, []int64{0}) {
+		t.Fatalf("unexpected deleted rows %v", deleted)
+	}
+	if rows := table.header().Rows; rows != 2 {
+		t.Fatalf("expected 2 rows after deleting, got %d", rows)
+	}
+	selected = nil
+	if err := table.SelectInto(&selected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(selected, values[1:]) {
+		t.Fatalf("expected %+v, got %+v", values[1:], selected)
+	}
+}
+
+type TestStructNullableKey struct {
+	Name string
+	Key  *string `gsheet:",unique"`
+}
+
+func TestNullableUniqueColumn(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
+
+	table, err := db.CreateTable(TestStructNullableKey{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the last column is blank, so the row read back is shorter than the columns
+	if err := table.UpsertIf([]interface{}{TestStructNullableKey{Name: "a"}}, true); err != nil {
+		t.Fatal(err)
+	}
+	key := "x"
+	values := []interface{}{
+		// NULL keys do not conflict
+		TestStructNullableKey{Name: "b"},
+		TestStructNullableKey{Name: "c", Key: &key},
+	}
+	if err := table.UpsertIf(values, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.UpsertIf([]interface{}{TestStructNullableKey{Name: "d", Key: &key}}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	var selected []TestStructNullableKey
+	if err := table.SelectInto(&selected); err != nil {
+		t.Fatal(err)
+	}
+	expected := []TestStructNullableKey{{Name: "a"}, {Name: "b"}, {Name: "c", Key: &key}}
+	if !reflect.DeepEqual(selected, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, selected)
+	}
+}
@@ -0,0 +1,206 @@
+package gosheet
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+const defaultDriveEndpoint = "https://www.googleapis.com/drive/v3/"
+
+// ManagerOption Configures a SheetManager on construction
+type ManagerOption func(*managerConfig)
+
+// managerConfig Settings collected from ManagerOption
+type managerConfig struct {
+	subject string
+	scopes  []string
+
+	client         *http.Client
+	sheetsEndpoint string
+	driveEndpoint  string
+
+	retry  RetryPolicy
+	limits RateLimits
+	clock  Clock
+
+	database DatabaseOptions
+}
+
+func newManagerConfig(opts []ManagerOption) *managerConfig {
+	cfg := &managerConfig{
+		scopes:        scope,
+		client:        http.DefaultClient,
+		driveEndpoint: defaultDriveEndpoint,
+		retry:         DefaultRetryPolicy(),
+		limits:        DefaultRateLimits(),
+		clock:         systemClock{},
+		database:      DefaultDatabaseOptions(),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// tokenContext Context for token sources, fetching tokens with the configured client
+func (cfg *managerConfig) tokenContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, cfg.client)
+}
+
+// WithSubject Impersonates the Workspace user `email` through domain-wide delegation,
+// so files are created in and read from the user's Drive.
+// Needs service account credentials.
+func WithSubject(email string) ManagerOption {
+	return func(cfg *managerConfig) {
+		cfg.subject = email
+	}
+}
+
+// WithScopes Requests `scopes` instead of the default drive and spreadsheets scopes.
+// e.g. drive.DriveFileScope and sheets.SpreadsheetsScope to reach only files created by the library.
+func WithScopes(scopes ...string) ManagerOption {
+	return func(cfg *managerConfig) {
+		cfg.scopes = scopes
+	}
+}
+
+// WithHTTPClient Sends every request, including token requests, through `client`
+// instead of http.DefaultClient. Use it for proxies, timeouts or custom transports.
+func WithHTTPClient(client *http.Client) ManagerOption {
+	return func(cfg *managerConfig) {
+		cfg.client = client
+	}
+}
+
+// WithSheetsEndpoint Base URL of the Sheets API, e.g. "http://localhost:8080/"
+// Defaults to "https://sheets.googleapis.com/"
+func WithSheetsEndpoint(endpoint string) ManagerOption {
+	return func(cfg *managerConfig) {
+		cfg.sheetsEndpoint = withTrailingSlash(endpoint)
+	}
+}
+
+// WithDriveEndpoint Base URL of the Drive v3 API, e.g. "http://localhost:8080/drive/v3/"
+// Defaults to "https://www.googleapis.com/drive/v3/"
+func WithDriveEndpoint(endpoint string) ManagerOption {
+	return func(cfg *managerConfig) {
+		cfg.driveEndpoint = withTrailingSlash(endpoint)
+	}
+}
+
+// WithRetryPolicy Retries failed Sheets and Drive requests following `policy`
+// instead of DefaultRetryPolicy. RetryPolicy{MaxAttempts: 1} disables retrying.
+func WithRetryPolicy(policy RetryPolicy) ManagerOption {
+	return func(cfg *managerConfig) {
+		cfg.retry = policy
+	}
+}
+
+// WithRateLimits Limits requests to `limits` instead of DefaultRateLimits.
+// Every request, including retries, waits for the limiter.
+// The limits apply per SheetManager. RateLimits{} disables limiting.
+func WithRateLimits(limits RateLimits) ManagerOption {
+	return func(cfg *managerConfig) {
+		cfg.limits = limits
+	}
+}
+
+// WithClock Uses `clock` for rate limiting, retry backoff and backup times instead of the system clock
+func WithClock(clock Clock) ManagerOption {
+	return func(cfg *managerConfig) {
+		cfg.clock = clock
+	}
+}
+
+// RecalcInterval How often volatile functions like NOW are recalculated
+type RecalcInterval string
+
+// Recalculation intervals of spreadsheets
+const (
+	RecalcOnChange RecalcInterval = "ON_CHANGE"
+	RecalcMinute   RecalcInterval = "MINUTE"
+	RecalcHour     RecalcInterval = "HOUR"
+)
+
+// DatabaseOptions Settings of the database files of a SheetManager.
+// Empty fields take the value of DefaultDatabaseOptions.
+type DatabaseOptions struct {
+	// Prefix Start of the titles of database files, found and listed by it
+	Prefix string
+	// TimeZone Time zone of new databases in the CLDR format, e.g. "America/New_York"
+	TimeZone string
+	// Locale Locale of new databases, e.g. "en_US". Empty follows the owner's locale.
+	Locale string
+	// AutoRecalc Recalculation interval of new databases
+	AutoRecalc RecalcInterval
+	// FolderID Drive folder to create, find and list databases in.
+	// Databases are found anywhere if empty, but new ones go to the root of My Drive.
+	FolderID string
+	// SharedDriveID Shared drive to create, find and list databases in, instead of My Drive.
+	// New databases go to the root of the shared drive unless FolderID is a folder in it.
+	SharedDriveID string
+}
+
+// CreateOptions Settings of a single database given to CreateDatabase and Restore.
+// Non-empty fields override the manager's DatabaseOptions for the new database.
+type CreateOptions struct {
+	// TimeZone Time zone of the database in the CLDR format, e.g. "America/New_York"
+	TimeZone string
+	// Locale Locale of the database, e.g. "en_US"
+	Locale string
+	// AutoRecalc Recalculation interval of the database
+	AutoRecalc RecalcInterval
+}
+
+// DefaultDatabaseOptions Options used unless WithDatabaseOptions is given
+func DefaultDatabaseOptions() DatabaseOptions {
+	return DatabaseOptions{
+		Prefix:     dbFileStart,
+		TimeZone:   "Asia/Seoul",
+		AutoRecalc: RecalcOnChange,
+	}
+}
+
+// WithDatabaseOptions Creates databases following `options`
+// and finds, lists and drops databases by `options.Prefix`.
+func WithDatabaseOptions(options DatabaseOptions) ManagerOption {
+	return func(cfg *managerConfig) {
+		defaults := DefaultDatabaseOptions()
+		if len(options.Prefix) == 0 {
+			options.Prefix = defaults.Prefix
+		}
+		if len(options.TimeZone) == 0 {
+			options.TimeZone = defaults.TimeZone
+		}
+		if len(options.AutoRecalc) == 0 {
+			options.AutoRecalc = defaults.AutoRecalc
+		}
+		cfg.database = options
+	}
+}
+
+// override `options` with non-empty TimeZone, Locale and AutoRecalc of `overrides`, the last one winning
+func (options DatabaseOptions) override(overrides ...CreateOptions) DatabaseOptions {
+	for _, o := range overrides {
+		if len(o.TimeZone) > 0 {
+			options.TimeZone = o.TimeZone
+		}
+		if len(o.Locale) > 0 {
+			options.Locale = o.Locale
+		}
+		if len(o.AutoRecalc) > 0 {
+			options.AutoRecalc = o.AutoRecalc
+		}
+	}
+	return options
+}
+
+func withTrailingSlash(endpoint string) string {
+	if strings.HasSuffix(endpoint, "/") {
+		return endpoint
+	}
+	return endpoint + "/"
+}
@@ -0,0 +1,160 @@
+package gosheet
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Clock Source of time for rate limiting, retrying and backups.
+// Replace it with WithClock to test without waiting.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// RateLimits Requests allowed per minute, following the per-user quotas of the Sheets API.
+// A zero rate does not limit the requests. Requests to the Drive API, which has far larger quotas, are not limited.
+// https://developers.google.com/sheets/api/limits
+type RateLimits struct {
+	// ReadsPerMinute Limit of GET requests
+	ReadsPerMinute int
+	// WritesPerMinute Limit of the other requests
+	WritesPerMinute int
+	// Burst Requests allowed at once before limiting. Defaults to 1.
+	Burst int
+}
+
+// DefaultRateLimits Limits used unless WithRateLimits is given.
+// A burst and a minute of refill stay within the quota of 60 requests per minute.
+func DefaultRateLimits() RateLimits {
+	return RateLimits{
+		ReadsPerMinute:  50,
+		WritesPerMinute: 50,
+		Burst:           10,
+	}
+}
+
+// tokenBucket Holds up to `capacity` tokens, refilled by `rate` tokens per second.
+// Tokens go negative when reserved ahead of time.
+type tokenBucket struct {
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(perMinute, burst int, now time.Time) *tokenBucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:     float64(perMinute) / 60,
+		capacity: float64(burst),
+		tokens:   float64(burst),
+		last:     now,
+	}
+}
+
+// reserve Takes a token and returns how long to wait for it
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+		b.last = now
+	}
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// rateLimiter Read and write token buckets of a SheetManager
+type rateLimiter struct {
+	clock Clock
+
+	mu    sync.Mutex // guards buckets
+	read  *tokenBucket
+	write *tokenBucket
+}
+
+func newRateLimiter(limits RateLimits, clock Clock) *rateLimiter {
+	now := clock.Now()
+	return &rateLimiter{
+		clock: clock,
+		read:  newTokenBucket(limits.ReadsPerMinute, limits.Burst, now),
+		write: newTokenBucket(limits.WritesPerMinute, limits.Burst, now),
+	}
+}
+
+// wait Blocks until a read or write is allowed.
+// Returns ctx.Err() if the context is done first, giving the token back.
+func (l *rateLimiter) wait(ctx context.Context, write bool) error {
+	l.mu.Lock()
+	bucket := l.read
+	if write {
+		bucket = l.write
+	}
+	if bucket == nil {
+		l.mu.Unlock()
+		return nil
+	}
+	delay := bucket.reserve(l.clock.Now())
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+
+	select {
+	case <-l.clock.After(delay):
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		bucket.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// isWrite Reports whether `req` counts against the write quota
+func isWrite(req *http.Request) bool {
+	return req.Method != http.MethodGet && req.Method != http.MethodHead
+}
+
+// limitTransport Waits for the rate limiter before sending each request but those to `driveEndpoint`
+type limitTransport struct {
+	base          http.RoundTripper
+	limiter       *rateLimiter
+	driveEndpoint string
+}
+
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.HasPrefix(req.URL.String(), t.driveEndpoint) {
+		return t.base.RoundTrip(req)
+	}
+	if err := t.limiter.wait(req.Context(), isWrite(req)); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,215 @@
+package gosheet
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock Clock moving only by Advance
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+	added   chan struct{}
+}
+
+type fakeWaiter struct {
+	deadline time.Time
+	c        chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:   time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		added: make(chan struct{}, 100),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{deadline: c.now.Add(d), c: ch})
+	c.added <- struct{}{}
+	return ch
+}
+
+// Advance Moves the clock by `d`, firing the waiters due
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	remaining := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.deadline.After(c.now) {
+			remaining = append(remaining, w)
+			continue
+		}
+		w.c <- c.now
+	}
+	c.waiters = remaining
+}
+
+// waitBlocked Waits until someone is blocked on the clock
+func (c *fakeClock) waitBlocked(t *testing.T) {
+	select {
+	case <-c.added:
+	case <-time.After(5 * time.Second):
+		t.Fatal("nobody blocked on the clock")
+	}
+}
+
+func TestRateLimiterBurst(t *testing.T) {
+	clock := newFakeClock()
+	limiter := newRateLimiter(RateLimits{ReadsPerMinute: 60, WritesPerMinute: 60, Burst: 2}, clock)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := limiter.wait(ctx, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(clock.added) != 0 {
+		t.Fatal("burst should not wait")
+	}
+
+	done := make(chan error)
+	go func() { done <- limiter.wait(ctx, false) }()
+	clock.waitBlocked(t)
+	select {
+	case <-done:
+		t.Fatal("third read should wait for a token")
+	default:
+	}
+	clock.Advance(500 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("token refills in 1 second")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.Advance(500 * time.Millisecond)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// reads do not take tokens from writes
+	for i := 0; i < 2; i++ {
+		if err := limiter.wait(ctx, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(clock.added) != 0 {
+		t.Fatal("writes should have their own burst")
+	}
+}
+
+func TestRateLimiterCancel(t *testing.T) {
+	clock := newFakeClock()
+	limiter := newRateLimiter(RateLimits{WritesPerMinute: 60}, clock)
+
+	if err := limiter.wait(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- limiter.wait(ctx, true) }()
+	clock.waitBlocked(t)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	// the canceled request gave its token back
+	clock.Advance(time.Second)
+	if err := limiter.wait(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	if len(clock.added) != 0 {
+		t.Fatal("token should be available after a second")
+	}
+
+	// reads are unlimited
+	for i := 0; i < 100; i++ {
+		if err := limiter.wait(context.Background(), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRateLimitedManager(t *testing.T) {
+	clock := newFakeClock()
+	manager, server := newTestManager(t,
+		WithClock(clock),
+		WithRateLimits(RateLimits{ReadsPerMinute: 60, WritesPerMinute: 60, Burst: 1}))
+	defer server.Close()
+
+	if _, err := manager.CreateDatabase("database_file_limited"); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		_, err := manager.CreateDatabase("database_file_limited_2")
+		done <- err
+	}()
+	for waits := 0; ; waits++ {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+			if waits == 0 {
+				t.Fatal("second database should wait for the limiter")
+			}
+			return
+		case <-clock.added:
+			clock.Advance(time.Second)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out")
+		}
+	}
+}
+
+func TestDriveRequestsNotLimited(t *testing.T) {
+	clock := newFakeClock()
+	manager, server := newTestManager(t,
+		WithClock(clock),
+		WithRateLimits(RateLimits{ReadsPerMinute: 60, WritesPerMinute: 60, Burst: 1}))
+	defer server.Close()
+
+	done := make(chan error)
+	go func() {
+		// Drive requests only, more than the burst
+		for i := 0; i < 5; i++ {
+			if _, err := manager.ListDatabases(); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-clock.added:
+		t.Fatal("drive requests should not wait for the limiter")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+	}
+	if n := len(server.Requests()); n != 5 {
+		t.Fatalf("expected 5 drive requests, got %d", n)
+	}
+}
@@ -0,0 +1,151 @@
+package gosheet
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy Decides how failed requests are retried.
+// 429 responses are retried for every request, since Google rejects them before doing anything.
+// 5xx responses and network errors are retried only for idempotent requests,
+// unless RetryWrites is set.
+type RetryPolicy struct {
+	// MaxAttempts Attempts including the first one. 1 or less disables retrying.
+	MaxAttempts int
+	// InitialBackoff Wait before the first retry
+	InitialBackoff time.Duration
+	// MaxBackoff Upper bound of the wait, except when Retry-After asks for longer
+	MaxBackoff time.Duration
+	// Multiplier Growth of the wait per retry
+	Multiplier float64
+	// RetryWrites Retries non-idempotent writes(e.g. adding a sheet) on 5xx and network errors.
+	// They may be applied twice.
+	RetryWrites bool
+}
+
+// DefaultRetryPolicy Policy used unless WithRetryPolicy is given.
+// Follows the truncated exponential backoff recommended by Google.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     32 * time.Second,
+		Multiplier:     2,
+	}
+}
+
+// backoff Jittered wait before retry number `retry`(from 1)
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	wait := float64(p.InitialBackoff)
+	for i := 1; i < retry; i++ {
+		wait *= p.Multiplier
+		if p.MaxBackoff > 0 && wait > float64(p.MaxBackoff) {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && wait > float64(p.MaxBackoff) {
+		wait = float64(p.MaxBackoff)
+	}
+	if wait < 1 {
+		return 0
+	}
+	// half fixed, half random
+	half := int64(wait / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+type idempotentKey struct{}
+
+// withIdempotentWrite Marks writes in `ctx` as safe to send twice,
+// e.g. overwriting fixed ranges of values
+func withIdempotentWrite(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	marked, _ := req.Context().Value(idempotentKey{}).(bool)
+	return marked
+}
+
+// retryTransport Retries requests following `policy`
+type retryTransport struct {
+	base   http.RoundTripper
+	policy RetryPolicy
+	clock  Clock
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	idempotent := isIdempotent(req)
+	// bodies must be read again for retrying
+	rewindable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	attempt := req
+	for retry := 1; ; retry++ {
+		resp, err := t.base.RoundTrip(attempt)
+		if retry >= t.policy.MaxAttempts || !rewindable || !t.shouldRetry(idempotent, resp, err) {
+			return resp, err
+		}
+
+		wait := t.policy.backoff(retry)
+		if resp != nil {
+			if after, ok := retryAfter(resp.Header.Get("Retry-After"), t.clock.Now()); ok && after > wait {
+				wait = after
+			}
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-t.clock.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		attempt = req.Clone(ctx)
+		if req.GetBody != nil {
+			if attempt.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (t *retryTransport) shouldRetry(idempotent bool, resp *http.Response, err error) bool {
+	if err != nil {
+		return idempotent || t.policy.RetryWrites
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotent || t.policy.RetryWrites
+	}
+	return false
+}
+
+// retryAfter Parses Retry-After in seconds or HTTP date
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	if len(value) == 0 {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,132 @@
+package gosheet
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/helloworldpark/gsheet-db-go/gsheettest"
+)
+
+// fastRetry Retry policy for tests
+var fastRetry = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     10 * time.Millisecond,
+	Multiplier:     2,
+}
+
+func TestRetryReads(t *testing.T) {
+	manager, server := newTestManager(t, WithRetryPolicy(fastRetry))
+	defer server.Close()
+	newTestDatabase(t, manager)
+
+	server.ResetRequests()
+	server.InjectFault(2, gsheettest.Fault{Status: http.StatusServiceUnavailable})
+	if _, err := manager.FindDatabase("testdb"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(server.Requests()); n != 4 {
+		t.Fatalf("expected 2 failures and 2 successful reads, got %d requests", n)
+	}
+
+	server.ResetRequests()
+	server.InjectFault(3, gsheettest.Fault{Status: http.StatusInternalServerError})
+	_, err := manager.FindDatabase("testdb")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode() != http.StatusInternalServerError {
+		t.Fatalf("expected 500 after exhausting attempts, got %v", err)
+	}
+	if n := len(server.Requests()); n != fastRetry.MaxAttempts {
+		t.Fatalf("expected %d attempts, got %d", fastRetry.MaxAttempts, n)
+	}
+}
+
+func TestRetryWrites(t *testing.T) {
+	manager, server := newTestManager(t, WithRetryPolicy(fastRetry))
+	defer server.Close()
+
+	// a failed write may have been applied, so it is not retried
+	server.InjectFault(1, gsheettest.Fault{Status: http.StatusServiceUnavailable, Method: http.MethodPost})
+	var apiErr *APIError
+	if _, err := manager.CreateDatabase("testdb"); !errors.As(err, &apiErr) || apiErr.StatusCode() != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %v", err)
+	}
+
+	// 429 is never applied
+	server.InjectFault(1, gsheettest.Fault{Status: http.StatusTooManyRequests, Method: http.MethodPost})
+	db, err := manager.CreateDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// values are written to fixed ranges, so writing them again is safe
+	table := findOrCreateTable(t, db, TestStructMeme{})
+	server.InjectFault(1, gsheettest.Fault{Status: http.StatusServiceUnavailable, Method: http.MethodPost})
+	if err := table.UpsertIf(memes(2), true); err != nil {
+		t.Fatal(err)
+	}
+	if table.header().Rows != 2 {
+		t.Fatalf("expected 2 rows, got %d", table.header().Rows)
+	}
+
+	retryWrites := fastRetry
+	retryWrites.RetryWrites = true
+	manager, server = newTestManager(t, WithRetryPolicy(retryWrites))
+	defer server.Close()
+	server.InjectFault(1, gsheettest.Fault{Status: http.StatusServiceUnavailable, Method: http.MethodPost})
+	if _, err := manager.CreateDatabase("testdb"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	manager, server := newTestManager(t, WithRetryPolicy(fastRetry))
+	defer server.Close()
+
+	server.InjectFault(1, gsheettest.Fault{Status: http.StatusTooManyRequests, RetryAfter: time.Second})
+	start := time.Now()
+	if _, err := manager.FindDatabase("testdb"); !errors.Is(err, ErrDatabaseNotFound) {
+		t.Fatalf("expected ErrDatabaseNotFound, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("Retry-After was not honored, retried after %v", elapsed)
+	}
+
+	if wait, ok := retryAfter("Wed, 21 Oct 2015 07:28:10 GMT", time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC)); !ok || wait != 10*time.Second {
+		t.Fatalf("unexpected wait %v", wait)
+	}
+	if _, ok := retryAfter("soon", time.Now()); ok {
+		t.Fatal("invalid Retry-After should be ignored")
+	}
+}
+
+func TestRetryCancel(t *testing.T) {
+	slow := fastRetry
+	slow.InitialBackoff = time.Hour
+	slow.MaxBackoff = time.Hour
+	manager, server := newTestManager(t, WithRetryPolicy(slow))
+	defer server.Close()
+
+	server.InjectFault(1, gsheettest.Fault{Status: http.StatusServiceUnavailable})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := manager.FindDatabaseContext(ctx, "testdb"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 4 * time.Second, Multiplier: 2}
+	for retry, max := range []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		if retry == 0 {
+			continue
+		}
+		wait := policy.backoff(retry)
+		if wait < max/2 || wait > max {
+			t.Fatalf("retry %d: wait %v out of [%v, %v]", retry, wait, max/2, max)
+		}
+	}
+}
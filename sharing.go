@@ -0,0 +1,183 @@
+package gosheet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Role Access granted to a database
+// https://developers.google.com/drive/api/v3/ref-roles
+type Role string
+
+// Roles grantable with Share
+const (
+	RoleReader    Role = "reader"
+	RoleCommenter Role = "commenter"
+	RoleWriter    Role = "writer"
+)
+
+// Permission Access to a database granted on Drive
+type Permission struct {
+	ID string `json:"id"`
+	// Type "user", "group", "domain" or "anyone"
+	Type string `json:"type"`
+	// Role Role of the permission. "owner" and "organizer" are not in the Role constants.
+	Role Role `json:"role"`
+	// EmailAddress Email address of users and groups
+	EmailAddress string `json:"emailAddress,omitempty"`
+	// Domain Domain of domain permissions
+	Domain string `json:"domain,omitempty"`
+}
+
+const permissionFields = "id,type,role,emailAddress,domain"
+
+// Share Grants `role` on the database to the user `email`.
+// Changes the role if the user already has one.
+// Drive sends a notification email to the user.
+func (db *Database) Share(email string, role Role) (*Permission, error) {
+	return db.ShareContext(context.Background(), email, role)
+}
+
+// ShareContext Share with context
+func (db *Database) ShareContext(ctx context.Context, email string, role Role) (*Permission, error) {
+	ctx = withOperation(ctx, "Database.Share")
+	return db.createPermission(ctx, map[string]interface{}{
+		"type":         "user",
+		"role":         role,
+		"emailAddress": email,
+	})
+}
+
+// ShareWithDomain Grants `role` on the database to everyone in the Workspace domain `domain`, e.g. "example.com".
+// The database is not listed in searches of the domain, only opened by its link.
+func (db *Database) ShareWithDomain(domain string, role Role) (*Permission, error) {
+	return db.ShareWithDomainContext(context.Background(), domain, role)
+}
+
+// ShareWithDomainContext ShareWithDomain with context
+func (db *Database) ShareWithDomainContext(ctx context.Context, domain string, role Role) (*Permission, error) {
+	ctx = withOperation(ctx, "Database.ShareWithDomain")
+	return db.createPermission(ctx, map[string]interface{}{
+		"type":               "domain",
+		"role":               role,
+		"domain":             domain,
+		"allowFileDiscovery": false,
+	})
+}
+
+// Unshare Removes the permissions of the user, group or domain `emailOrDomain`.
+// Does nothing if there is none. The owner cannot be removed.
+func (db *Database) Unshare(emailOrDomain string) error {
+	return db.UnshareContext(context.Background(), emailOrDomain)
+}
+
+// UnshareContext Unshare with context
+func (db *Database) UnshareContext(ctx context.Context, emailOrDomain string) error {
+	ctx = withOperation(ctx, "Database.Unshare")
+	permissions, err := db.listPermissions(ctx)
+	if err != nil {
+		return err
+	}
+	for _, p := range permissions {
+		if !strings.EqualFold(p.EmailAddress, emailOrDomain) && !(p.Type == "domain" && strings.EqualFold(p.Domain, emailOrDomain)) {
+			continue
+		}
+		if err := db.deletePermission(ctx, p.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListPermissions Lists who can access the database, the owner included
+func (db *Database) ListPermissions() ([]*Permission, error) {
+	return db.ListPermissionsContext(context.Background())
+}
+
+// ListPermissionsContext ListPermissions with context
+func (db *Database) ListPermissionsContext(ctx context.Context) ([]*Permission, error) {
+	ctx = withOperation(ctx, "Database.ListPermissions")
+	return db.listPermissions(ctx)
+}
+
+// permissionsURL URL of the permissions of the database, or of `permissionID` if given
+func (db *Database) permissionsURL(permissionID ...string) string {
+	u := db.manager.driveEndpoint + "files/" + url.PathEscape(db.ID()) + "/permissions"
+	for _, id := range permissionID {
+		u += "/" + url.PathEscape(id)
+	}
+	return u
+}
+
+func (db *Database) createPermission(ctx context.Context, body map[string]interface{}) (*Permission, error) {
+	req, err := newJSONRequest(ctx, db.manager, post, db.permissionsURL(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.AddQuery("supportsAllDrives", "true")
+	req.AddQuery("fields", permissionFields)
+	resp, err := req.Do("drive.permissions.create")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var permission Permission
+	if err := json.NewDecoder(resp.Body).Decode(&permission); err != nil {
+		return nil, apiError("drive.permissions.create", err)
+	}
+	return &permission, nil
+}
+
+func (db *Database) listPermissions(ctx context.Context) ([]*Permission, error) {
+	var permissions []*Permission
+	pageToken := ""
+	for {
+		req, err := newURLRequest(ctx, db.manager, get, db.permissionsURL())
+		if err != nil {
+			return nil, err
+		}
+		req.AddQuery("supportsAllDrives", "true")
+		req.AddQuery("fields", fmt.Sprintf("nextPageToken,permissions(%s)", permissionFields))
+		req.AddQuery("pageSize", "100")
+		if len(pageToken) > 0 {
+			req.AddQuery("pageToken", pageToken)
+		}
+		resp, err := req.Do("drive.permissions.list")
+		if err != nil {
+			return nil, err
+		}
+		var list struct {
+			NextPageToken string        `json:"nextPageToken"`
+			Permissions   []*Permission `json:"permissions"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if err != nil {
+			return nil, apiError("drive.permissions.list", err)
+		}
+
+		permissions = append(permissions, list.Permissions...)
+		if len(list.NextPageToken) == 0 {
+			return permissions, nil
+		}
+		pageToken = list.NextPageToken
+	}
+}
+
+func (db *Database) deletePermission(ctx context.Context, permissionID string) error {
+	req, err := newURLRequest(ctx, db.manager, delete, db.permissionsURL(permissionID))
+	if err != nil {
+		return err
+	}
+	req.AddQuery("supportsAllDrives", "true")
+	resp, err := req.Do("drive.permissions.delete")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,73 @@
+package gosheet
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/helloworldpark/gsheet-db-go/gsheettest"
+)
+
+func TestSharing(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
+
+	reader, err := db.Share("ops@example.com", RoleReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reader.Type != "user" || reader.Role != RoleReader || reader.EmailAddress != "ops@example.com" {
+		t.Fatalf("unexpected permission %+v", reader)
+	}
+	commenter, err := db.Share("ops@example.com", RoleCommenter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commenter.ID != reader.ID || commenter.Role != RoleCommenter {
+		t.Fatalf("sharing again should change the role, got %+v", commenter)
+	}
+	domain, err := db.ShareWithDomain("example.com", RoleReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if domain.Type != "domain" || domain.Domain != "example.com" {
+		t.Fatalf("unexpected permission %+v", domain)
+	}
+
+	permissions, err := db.ListPermissions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	roles := make(map[string]Role)
+	for _, p := range permissions {
+		roles[p.EmailAddress+p.Domain] = p.Role
+	}
+	if len(permissions) != 3 || roles[gsheettest.Owner] != "owner" || roles["ops@example.com"] != RoleCommenter || roles["example.com"] != RoleReader {
+		t.Fatalf("unexpected permissions %v", roles)
+	}
+
+	for _, who := range []string{"OPS@example.com", "example.com", "nobody@example.com"} {
+		if err := db.Unshare(who); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if permissions, err = db.ListPermissions(); err != nil {
+		t.Fatal(err)
+	}
+	if len(permissions) != 1 || permissions[0].EmailAddress != gsheettest.Owner {
+		t.Fatalf("expected only the owner, got %+v", permissions[0])
+	}
+
+	err = db.Unshare(gsheettest.Owner)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode() != http.StatusForbidden {
+		t.Fatalf("expected 403 removing the owner, got %v", err)
+	}
+	if _, err := db.Share("not an email", RoleReader); !errors.As(err, &apiErr) || apiErr.StatusCode() != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid email, got %v", err)
+	}
+	if ops := manager.Usage().Operations; ops["Database.Share"].Writes != 3 || ops["Database.Unshare"].Reads != 4 {
+		t.Fatalf("unexpected usage %+v", ops)
+	}
+}
@@ -1,10 +1,17 @@
 package gosheet
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
 	"reflect"
 	"strconv"
+	"sync"
+	"time"
 
 	"google.golang.org/api/sheets/v4"
 )
@@ -14,130 +21,220 @@ import (
  */
 
 // CreateTable Creates a new sheet(a.k.a. table) with `tableName` on the given Spreadsheet(a.k.a. database).
+// Columns are the exported fields of `scheme`, renamed or skipped by `gsheet` struct tags:
+// `gsheet:"name"`, `gsheet:"-"`, `gsheet:",unique"`, `gsheet:",omitempty"` and `gsheet:",json"`.
+// Slices, maps, arrays and interfaces are stored as JSON of up to 50,000 characters.
+// Pointer fields are nullable columns, and nil is written as a blank cell.
+// Like SQL, rows with NULL in a unique column never conflict with each other.
+// Fields tagged unique make the unique constraint unless `constraint` is given.
+// Nested structs are flattened into columns prefixed with the field name, e.g. Address.City,
+// and fields of embedded structs are promoted. Fields under a nil embedded *T are blank cells,
+// and SelectInto allocates the *T unless they are all blank. Read them back with SelectInto.
 // Case handling:
-// database == nil: return nil
-// database != nil: log as creating, and return the created sheet with true
-func (db *Database) CreateTable(scheme interface{}, constraint ...*Constraint) *Table {
-	db.Manager().enqueueAPIUsage(4, false)
-	return db.createTable(scheme, constraint...)
+// table exists: returns ErrTableExists
+// else: returns the created table
+func (db *Database) CreateTable(scheme interface{}, constraint ...*Constraint) (*Table, error) {
+	return db.CreateTableContext(context.Background(), scheme, constraint...)
 }
-func (db *Database) createTable(scheme interface{}, constraint ...*Constraint) *Table {
+
+// CreateTableContext CreateTable with context
+func (db *Database) CreateTableContext(ctx context.Context, scheme interface{}, constraint ...*Constraint) (*Table, error) {
+	ctx = withOperation(ctx, "Database.CreateTable")
+	return db.createTable(ctx, scheme, constraint...)
+}
+func (db *Database) createTable(ctx context.Context, scheme interface{}, constraint ...*Constraint) (*Table, error) {
 	// check if spreadsheet exists in local
 	if db.Spreadsheet() == nil {
-		// todo: log
-		return nil
+		return nil, errors.New("gosheet: database has no spreadsheet")
 	}
-	tableName := reflect.TypeOf(scheme).Name()
-	for _, sheet := range db.spreadsheet.Sheets {
-		if sheet.Properties.Title == tableName {
-			panic(fmt.Sprintf("Has table with name %s", tableName))
-		}
+	tableName, err := nameOfStruct(scheme)
+	if err != nil {
+		return nil, err
 	}
-	// add sheet
-	// api call: 1
-	request := make([]*sheets.Request, 1)
-	request[0] = &sheets.Request{}
-	request[0].AddSheet = &sheets.AddSheetRequest{}
-	request[0].AddSheet.Properties = &sheets.SheetProperties{}
-	request[0].AddSheet.Properties.Title = tableName
-	newDatabase, _, statusCode := db.batchUpdate(request)
-	if statusCode/100 != 2 {
-		return nil
-	}
-	var newSheet *sheets.Sheet
-	for _, sheet := range newDatabase.Sheets() {
+	for _, sheet := range db.Sheets() {
 		if sheet.Properties.Title == tableName {
-			newSheet = sheet
-			break
+			return nil, fmt.Errorf("%w: %s", ErrTableExists, tableName)
 		}
 	}
-	// apply requests
+	// build header before touching the spreadsheet
+	requests, err := createColumnsFromStruct(scheme, constraint...)
+	if err != nil {
+		return nil, err
+	}
+	return db.addTable(ctx, tableName, requests)
+}
+
+// addTable Adds sheet `tableName` and writes its header with `requests`.
+// Both go in a single batchUpdate, so a failed header write leaves no sheet behind.
+func (db *Database) addTable(ctx context.Context, tableName string, requests []*sheets.Request) (*Table, error) {
+	// the header refers to the new sheet by id, so pick one at random like Sheets does.
+	// other sheets may be added meanwhile, so a rejected id is tried once more with another.
 	// api call: 1
-	requests := db.manager.createColumnsFromStruct(newSheet, scheme, constraint...)
-	updated, _, statusCode := db.batchUpdate(requests)
-	if updated == nil {
-		return nil
+	sheetID := newSheetID()
+	updated, _, err := db.batchUpdate(ctx, addSheetRequests(tableName, sheetID, requests))
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode() == http.StatusBadRequest {
+		sheetID = newSheetID()
+		updated, _, err = db.batchUpdate(ctx, addSheetRequests(tableName, sheetID, requests))
+	}
+	if err != nil {
+		return nil, err
 	}
 	// create *table
 	// api call: 2
 	for _, updatedSheet := range updated.Sheets() {
-		if updatedSheet.Properties.SheetId == newSheet.Properties.SheetId {
-			newTable := db.newTableFromSheet(updatedSheet)
-			return newTable
+		if updatedSheet.Properties.SheetId == sheetID {
+			return db.newTableFromSheet(ctx, updatedSheet)
 		}
 	}
-	return nil
+	return nil, fmt.Errorf("%w: %s", ErrTableNotFound, tableName)
+}
+
+// addSheetRequests Requests adding sheet `tableName` with `sheetID` and applying `requests` to it
+func addSheetRequests(tableName string, sheetID int64, requests []*sheets.Request) []*sheets.Request {
+	request := make([]*sheets.Request, 1, 1+len(requests))
+	request[0] = &sheets.Request{}
+	request[0].AddSheet = &sheets.AddSheetRequest{}
+	request[0].AddSheet.Properties = &sheets.SheetProperties{}
+	request[0].AddSheet.Properties.Title = tableName
+	request[0].AddSheet.Properties.SheetId = sheetID
+	// as wide as the header, which may have more columns than the default grid
+	request[0].AddSheet.Properties.GridProperties = &sheets.GridProperties{}
+	request[0].AddSheet.Properties.GridProperties.ColumnCount = headerWidth(requests[0])
+	// sheet id 0 is omitted from the request unless forced
+	request[0].AddSheet.Properties.ForceSendFields = []string{"SheetId"}
+	requests[0].UpdateCells.Range.SheetId = sheetID
+	requests[0].UpdateCells.Range.ForceSendFields = []string{"SheetId"}
+	return append(request, requests...)
+}
+
+var (
+	sheetIDMu   sync.Mutex // guards sheetIDRand
+	sheetIDRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// newSheetID Random non-negative int32 for the id of a new sheet
+func newSheetID() int64 {
+	sheetIDMu.Lock()
+	defer sheetIDMu.Unlock()
+	return sheetIDRand.Int63n(math.MaxInt32 + 1)
+}
+
+// headerWidth Number of columns written by the header request, at least 3 for numrows, numcols and constraints
+func headerWidth(request *sheets.Request) int64 {
+	width := int64(3)
+	for _, row := range request.UpdateCells.Rows {
+		width = maximum64(width, int64(len(row.Values)))
+	}
+	return width
 }
 
 // FindTable Gets an existing sheet(a.k.a. table) with `tableName` on the given Spreadsheet(a.k.a. database)
 // If exists, returns the existed one
-// If not existing, returns nil
-func (db *Database) FindTable(str interface{}) *Table {
-	table, others := db.findTable(str)
-	db.Manager().enqueueAPIUsage(others, false)
-	return table
-}
-func (db *Database) findTable(str interface{}) (*Table, int64) {
-	tableName := reflect.TypeOf(str).Name()
-	tables := db.listTables()
-	for _, table := range tables {
-		if table.Name() == tableName {
-			return table, int64(len(tables))
+// If not existing, returns ErrTableNotFound
+// If its header rows are unreadable, returns ErrCorruptMetadata
+func (db *Database) FindTable(str interface{}) (*Table, error) {
+	return db.FindTableContext(context.Background(), str)
+}
+
+// FindTableContext FindTable with context
+func (db *Database) FindTableContext(ctx context.Context, str interface{}) (*Table, error) {
+	ctx = withOperation(ctx, "Database.FindTable")
+	return db.findTable(ctx, str)
+}
+func (db *Database) findTable(ctx context.Context, str interface{}) (*Table, error) {
+	tableName, err := nameOfStruct(str)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Manager().synchronizeFromGoogle(ctx, db); err != nil {
+		return nil, err
+	}
+	// read the header of this table only, so other broken sheets do not matter
+	for _, sheet := range db.Sheets() {
+		if sheet.Properties.Title == tableName && db.isValidTable(sheet) {
+			return db.newTableFromSheet(ctx, sheet)
 		}
 	}
-	return nil, int64(len(tables))
+	return nil, fmt.Errorf("%w: %s", ErrTableNotFound, tableName)
 }
 
 // ListTables Gets an existing sheets(a.k.a. table) in the given Spreadsheet(a.k.a. database).
+// Sheets whose header rows are unreadable are skipped.
 // If exists, returns the existings
-// If not existing, returns nil
-func (db *Database) ListTables() []*Table {
-	tables := db.listTables()
-	db.Manager().enqueueAPIUsage(1+int64(len(tables)), false)
-	return tables
-}
-func (db *Database) listTables() []*Table {
-	db.Manager().synchronizeFromGoogle(db)
+// If not existing, returns empty slice
+func (db *Database) ListTables() ([]*Table, error) {
+	return db.ListTablesContext(context.Background())
+}
+
+// ListTablesContext ListTables with context
+func (db *Database) ListTablesContext(ctx context.Context) ([]*Table, error) {
+	ctx = withOperation(ctx, "Database.ListTables")
+	return db.listTables(ctx)
+}
+func (db *Database) listTables(ctx context.Context) ([]*Table, error) {
+	if err := db.Manager().synchronizeFromGoogle(ctx, db); err != nil {
+		return nil, err
+	}
 	sheets := db.Sheets()
 	tables := make([]*Table, 0)
 	for i := range sheets {
 		if !db.isValidTable(sheets[i]) {
 			continue
 		}
-		newTable := db.newTableFromSheet(sheets[i])
-		if newTable != nil {
-			tables = append(tables, newTable)
+		newTable, err := db.newTableFromSheet(ctx, sheets[i])
+		if errors.Is(err, ErrCorruptMetadata) {
+			// not a table of this library, or a broken one
+			continue
 		}
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, newTable)
 	}
-	return tables
+	return tables, nil
 }
 
-func (db *Database) batchUpdate(requests []*sheets.Request) (*Database, []*sheets.Response, int) {
-	spreadsheet, responses, statusCode := newSpreadsheetBatchUpdateRequest(db.manager, db.Spreadsheet().SpreadsheetId, requests...).Do()
+func (db *Database) batchUpdate(ctx context.Context, requests []*sheets.Request) (*Database, []*sheets.Response, error) {
+	spreadsheet, responses, err := newSpreadsheetBatchUpdateRequest(db.manager, db.Spreadsheet().SpreadsheetId, requests...).Do(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
 	updated := &Database{
 		manager:     db.manager,
 		spreadsheet: spreadsheet,
 	}
-	return updated, responses, statusCode
+	return updated, responses, nil
 }
 
-// Table Wrapper of the table(spreadsheet.sheet)
+// Table Wrapper of the table(spreadsheet.sheet).
+// Safe for concurrent use. Writes to the same table are serialized
+// across every *Table of the same SheetManager.
 type Table struct {
 	manager  *SheetManager
 	database *Database
 	sheet    *sheets.Sheet
-	scheme   *TableScheme
-	index    *tableIndex
+
+	mu     sync.RWMutex // guards fields below
+	scheme *TableScheme
+	index  *tableIndex
+	// version of the write lock when scheme was read
+	syncedVersion int64
 }
 
 // TableScheme Metadata of the table
+// Do not modify a TableScheme returned by the library, it is shared.
 type TableScheme struct {
-	Name        string
-	Columns     []string
-	ColumnMap   map[string]int64
+	Name      string
+	Columns   []string
+	ColumnMap map[string]int64
+	// Types Kinds of the columns. reflect.Struct for time.Time, reflect.Int64 for time.Duration
+	// and reflect.Interface for JSON columns.
 	Types       []reflect.Kind
 	Rows        int64
 	Constraints *Constraint
+	// typeNames Types as written in the metadata row, e.g. "int64" or "time"
+	typeNames []string
 }
 
 // Predicate Check if the given interface fits the condition
@@ -147,64 +244,115 @@ type Predicate func(interface{}) bool
 type ArrayPredicate func([]interface{}) bool
 
 // Drop Drops the table(a.k.a. sheet).
-// If exists, deletes and returns true
-// If not existing, logs and returns false
 // Always update
-func (table *Table) Drop() bool {
+func (table *Table) Drop() error {
+	return table.DropContext(context.Background())
+}
+
+// DropContext Drop with context
+func (table *Table) DropContext(ctx context.Context) error {
+	ctx = withOperation(ctx, "Table.Drop")
+	lock := table.writeLock()
+	lock.Lock()
+	defer lock.Unlock()
+	defer lock.bump()
+
 	request := make([]*sheets.Request, 1)
 	request[0] = &sheets.Request{}
 	request[0].DeleteSheet = &sheets.DeleteSheetRequest{}
 	request[0].DeleteSheet.SheetId = table.sheetID()
-	resp, _, _ := table.database.batchUpdate(request)
-	table.manager.synchronizeFromGoogle(table.database)
-	return resp != nil
+	if _, _, err := table.database.batchUpdate(ctx, request); err != nil {
+		return err
+	}
+	return table.manager.synchronizeFromGoogle(ctx, table.database)
 }
 
 // Select Selects all the rows from the table
-func (table *Table) Select(rows int64) ([][]interface{}, *TableScheme) {
-	table.manager.enqueueAPIUsage(1, true)
-	return table.selectData(rows)
+// Cells of time columns are time.Time in the timezone of the database, and of duration columns time.Duration.
+// Cells of JSON columns are decoded as by json.Unmarshal into interface{}.
+// NULL cells of nullable columns, pointer fields of the scheme, are nil.
+func (table *Table) Select(rows int64) ([][]interface{}, *TableScheme, error) {
+	return table.SelectContext(context.Background(), rows)
+}
+
+// SelectContext Select with context
+func (table *Table) SelectContext(ctx context.Context, rows int64) ([][]interface{}, *TableScheme, error) {
+	ctx = withOperation(ctx, "Table.Select")
+	if err := table.syncIfStale(ctx, table.writeLock()); err != nil {
+		return nil, nil, err
+	}
+	data, metadata, err := table.selectData(ctx, rows)
+	if err != nil {
+		return nil, metadata, err
+	}
+	return table.decodeRows(data, metadata), metadata, nil
 }
-func (table *Table) selectData(rows int64) ([][]interface{}, *TableScheme) {
+func (table *Table) selectData(ctx context.Context, rows int64) ([][]interface{}, *TableScheme, error) {
+	return table.selectRows(ctx, rows, false)
+}
+
+// selectRows Reads `rows` rows, all if -1, with numbers and booleans as they are if `unformatted`
+func (table *Table) selectRows(ctx context.Context, rows int64, unformatted bool) ([][]interface{}, *TableScheme, error) {
 	metadata := table.header()
 	if metadata == nil {
-		fmt.Println("Select: Metadata is nil")
-		return nil, nil
+		return nil, nil, corruptMetadata(table.Name(), "no metadata")
 	}
 	if metadata.Rows == 0 {
-		fmt.Println("Select: Metadata.rows is 0")
-		return nil, metadata
+		return nil, metadata, nil
 	}
 	if rows == 0 {
-		fmt.Println("Select: rows is 0")
-		return nil, metadata
+		return nil, metadata, nil
 	} else if rows == -1 {
 		rows = metadata.Rows
 	}
 
 	// 3행~, 모든 열을 읽는다
 	req := newSpreadsheetValuesRequest(table.manager, table.spreadsheet().SpreadsheetId, metadata.Name)
-	req.updateRange(metadata.Name, 3, 0, 3+rows, int64(len(metadata.Columns)))
-	valueRange := req.Do()
+	if err := req.updateRange(metadata.Name, 3, 0, 3+rows, int64(len(metadata.Columns))); err != nil {
+		return nil, metadata, err
+	}
+	if unformatted {
+		req.unformatted()
+	}
+	valueRange, err := req.Do(ctx)
+	if err != nil {
+		return nil, metadata, err
+	}
 
-	return valueRange.Values, metadata
+	// trailing empty rows, e.g. all NULL, are not read
+	values := valueRange.Values
+	for int64(len(values)) < mininum64(rows, metadata.Rows) {
+		values = append(values, []interface{}{})
+	}
+	return values, metadata, nil
 }
 
 // SelectAndFilter Select rows satisfying filter
 // filters.key: int, column index
 // filters.value: Predicate, whether to select or not
-func (table *Table) SelectAndFilter(filters map[int]Predicate) ([][]interface{}, *TableScheme) {
-	table.manager.enqueueAPIUsage(1, true)
-	return table.selectAndFilter(filters)
+func (table *Table) SelectAndFilter(filters map[int]Predicate) ([][]interface{}, *TableScheme, error) {
+	return table.SelectAndFilterContext(context.Background(), filters)
 }
-func (table *Table) selectAndFilter(filters map[int]Predicate) ([][]interface{}, *TableScheme) {
-	fullData, _ := table.selectData(-1)
-	metadata := table.header()
+
+// SelectAndFilterContext SelectAndFilter with context
+func (table *Table) SelectAndFilterContext(ctx context.Context, filters map[int]Predicate) ([][]interface{}, *TableScheme, error) {
+	ctx = withOperation(ctx, "Table.SelectAndFilter")
+	if err := table.syncIfStale(ctx, table.writeLock()); err != nil {
+		return nil, nil, err
+	}
+	return table.selectAndFilter(ctx, filters)
+}
+func (table *Table) selectAndFilter(ctx context.Context, filters map[int]Predicate) ([][]interface{}, *TableScheme, error) {
+	fullData, metadata, err := table.selectData(ctx, -1)
+	if err != nil {
+		return nil, metadata, err
+	}
+	fullData = table.decodeRows(fullData, metadata)
 	if len(filters) == 0 {
-		return fullData, metadata
+		return fullData, metadata, nil
 	}
 	if len(fullData) == 0 {
-		return fullData, metadata
+		return fullData, metadata, nil
 	}
 
 	filtered := make([][]interface{}, 0)
@@ -219,42 +367,83 @@ func (table *Table) selectAndFilter(filters map[int]Predicate) ([][]interface{},
 			filtered = append(filtered, fullData[i])
 		}
 	}
-	return filtered, metadata
+	return filtered, metadata, nil
 }
 
-// UpsertIf Upserts given `values`. Returns true if success.
+// UpsertIf Upserts given `values`.
+// Returns ErrSchemaMismatch if values do not fit the table's scheme.
 // condition.key: column index
-func (table *Table) UpsertIf(values []interface{}, appendData bool, conditions ...map[int]Predicate) bool {
-	table.manager.enqueueAPIUsage(2, true)
-	return table.upsertIf(values, appendData, conditions...)
+// condition.value: Predicate given the column value decoded as by SelectAndFilter
+func (table *Table) UpsertIf(values []interface{}, appendData bool, conditions ...map[int]Predicate) error {
+	return table.UpsertIfContext(context.Background(), values, appendData, conditions...)
+}
+
+// UpsertIfContext UpsertIf with context
+func (table *Table) UpsertIfContext(ctx context.Context, values []interface{}, appendData bool, conditions ...map[int]Predicate) error {
+	ctx = withOperation(ctx, "Table.UpsertIf")
+	return table.upsertIf(ctx, values, appendData, conditions...)
 }
-func (table *Table) upsertIf(values []interface{}, appendData bool, conditions ...map[int]Predicate) bool {
+func (table *Table) upsertIf(ctx context.Context, values []interface{}, appendData bool, conditions ...map[int]Predicate) error {
 	if len(values) == 0 {
-		return false
+		return nil
 	}
+	lock := table.writeLock()
+	lock.Lock()
+	defer lock.Unlock()
+	if err := table.syncIfStale(ctx, lock); err != nil {
+		return err
+	}
+	return table.upsertLocked(ctx, lock, values, appendData, conditions...)
+}
 
-	defer func() {
-		// sync
-		table.updatedHeader()
-		table.updateIndex()
-	}()
-
+// upsertLocked upsertIf holding the write lock
+func (table *Table) upsertLocked(ctx context.Context, lock *tableWriteLock, values []interface{}, appendData bool, conditions ...map[int]Predicate) (err error) {
 	scheme := table.header()
 	if scheme == nil {
-		return false
+		return corruptMetadata(table.Name(), "no metadata")
 	}
 	if !scheme.fitsScheme(values[0]) {
-		fmt.Println("Input does not match table's scheme")
-		return false
+		return fmt.Errorf("%w: table %s", ErrSchemaMismatch, scheme.Name)
 	}
 
+	defer func() {
+		// sync
+		if syncErr := table.sync(ctx); err == nil {
+			err = syncErr
+		}
+	}()
+
 	newValues := make([][]interface{}, 0)
 	for i := range values {
 		columnValues, ok := values[i].([]interface{})
-		if !ok {
-			columnwiseAnalyse := analyseStruct(values[i])
+		if ok {
+			encoded := make([]interface{}, len(columnValues))
+			for j := range columnValues {
+				if j < len(scheme.typeNames) && scheme.typeNames[j] == jsonTypeName {
+					// anything in a json column, structs too
+					encoded[j], err = marshalCell(columnValues[j])
+				} else {
+					encoded[j], err = encodeCell(columnValues[j])
+				}
+				if err != nil {
+					return err
+				}
+			}
+			columnValues = encoded
+		} else {
+			if !scheme.fitsScheme(values[i]) {
+				return fmt.Errorf("%w: table %s", ErrSchemaMismatch, scheme.Name)
+			}
+			columnwiseAnalyse, err := analyseStruct(values[i])
+			if err != nil {
+				return err
+			}
 			for _, v := range columnwiseAnalyse {
-				columnValues = append(columnValues, v.cvalue)
+				encoded, err := encodeCell(v.cvalue)
+				if err != nil {
+					return err
+				}
+				columnValues = append(columnValues, encoded)
 			}
 		}
 		// constraint check
@@ -268,12 +457,14 @@ func (table *Table) upsertIf(values []interface{}, appendData bool, conditions .
 	if len(conditions) == 0 {
 		filteredValues = newValues
 	} else {
+		// conditions see values as SelectAndFilter does, e.g. nil for NULL
+		decoded := table.decodeRows(newValues, scheme)
 		for i := range newValues {
 			didPass := true
 		TESTITEM:
 			for _, condition := range conditions {
 				for j, f := range condition {
-					if !f(newValues[i][j]) {
+					if !f(decoded[i][j]) {
 						didPass = false
 						break TESTITEM
 					}
@@ -284,72 +475,148 @@ func (table *Table) upsertIf(values []interface{}, appendData bool, conditions .
 			}
 		}
 	}
+	filteredValues = table.dropDuplicateKeys(filteredValues)
 	if len(filteredValues) > 0 {
 		// 데이터를 덧붙인다면 마지막 행부터
 		// 처음부터라면 첫 행부터
 		// 기록한 행 업데이트도 같이 한다
 		req := newSpreadsheetValuesBatchUpdateRequest(table.manager, table.spreadsheet().SpreadsheetId, scheme.Name)
-		req.updateRange(scheme, appendData, filteredValues)
+		if err := req.updateRange(scheme, appendData, filteredValues); err != nil {
+			return err
+		}
 		req.updateRows(scheme, appendData, len(filteredValues))
 
-		if req.Do()/100 != 2 {
-			return false
+		// the request may have been applied even if failed
+		defer lock.bump()
+		if err := req.Do(ctx); err != nil {
+			return err
 		}
 	}
 
-	return true
+	return nil
 }
 
 // Delete Deletes and returns deleted rows
 // deleteThis: input - array of row values
 // returns: array of rows starting from 0
-func (table *Table) Delete(deleteThis ArrayPredicate) []int64 {
-	table.manager.enqueueAPIUsage(3, true)
-	return table.delete(deleteThis)
+func (table *Table) Delete(deleteThis ArrayPredicate) ([]int64, error) {
+	return table.DeleteContext(context.Background(), deleteThis)
+}
+
+// DeleteContext Delete with context
+func (table *Table) DeleteContext(ctx context.Context, deleteThis ArrayPredicate) ([]int64, error) {
+	ctx = withOperation(ctx, "Table.Delete")
+	return table.delete(ctx, deleteThis)
 }
-func (table *Table) delete(deleteThis ArrayPredicate) []int64 {
+func (table *Table) delete(ctx context.Context, deleteThis ArrayPredicate) (deletedIndex []int64, err error) {
+	lock := table.writeLock()
+	lock.Lock()
+	defer lock.Unlock()
+	if err := table.syncIfStale(ctx, lock); err != nil {
+		return nil, err
+	}
+
 	defer func() {
 		// sync
-		table.updatedHeader()
-		table.updateIndex()
+		if syncErr := table.sync(ctx); err == nil {
+			err = syncErr
+		}
 	}()
 	// call data
-	data, scheme := table.selectData(-1)
+	data, scheme, err := table.selectData(ctx, -1)
+	if err != nil {
+		return nil, err
+	}
 	if data == nil {
-		return nil
+		return nil, nil
 	}
 
 	// delete if predicate==true
-	newData := make([]interface{}, 0)
-	deletedIndex := make([]int64, 0)
+	// the predicate sees decoded values, but the rows are written back as they are
+	decoded := table.decodeRows(data, scheme)
+	newData := make([][]interface{}, 0)
+	deletedIndex = make([]int64, 0)
 	for i, values := range data {
-		if deleteThis(values) {
+		if deleteThis(decoded[i]) {
 			// add to deleted index
 			deletedIndex = append(deletedIndex, int64(i))
 		} else {
+			// trailing blank cells, e.g. NULL, are not read
+			for len(values) < len(scheme.Columns) {
+				values = append(values, "")
+			}
 			newData = append(newData, values)
 		}
 	}
 
 	if len(deletedIndex) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	// delete every data
 	if len(data) == len(deletedIndex) {
 		req := newSpreadsheetValuesBatchUpdateRequest(table.manager, table.spreadsheet().SpreadsheetId, scheme.Name)
 		req.updateRows(scheme, false, 0)
-		if req.Do()/100 != 2 {
-			return nil
+		defer lock.bump()
+		if err := req.Do(ctx); err != nil {
+			return nil, err
 		}
-		return deletedIndex
+		return deletedIndex, nil
 	}
 
 	// update deleted data
-	table.upsertIf(newData, false)
+	// kept rows are in the index, so they are written without the constraint check of upserting
+	req := newSpreadsheetValuesBatchUpdateRequest(table.manager, table.spreadsheet().SpreadsheetId, scheme.Name)
+	if err := req.updateRange(scheme, false, newData); err != nil {
+		return nil, err
+	}
+	req.updateRows(scheme, false, len(newData))
+	defer lock.bump()
+	if err := req.Do(ctx); err != nil {
+		return nil, err
+	}
 
 	// return index
-	return deletedIndex
+	return deletedIndex, nil
+}
+
+// truncate Removes every row, clearing the cells unlike deleting every row
+func (table *Table) truncate(ctx context.Context) (err error) {
+	lock := table.writeLock()
+	lock.Lock()
+	defer lock.Unlock()
+	if err := table.syncIfStale(ctx, lock); err != nil {
+		return err
+	}
+	scheme := table.header()
+	if scheme == nil {
+		return corruptMetadata(table.Name(), "no metadata")
+	}
+
+	defer func() {
+		// sync
+		if syncErr := table.sync(ctx); err == nil {
+			err = syncErr
+		}
+	}()
+	defer lock.bump()
+	// to the end of the sheet, since deleted rows may remain after the last row
+	lastRow := tableDataStartRowIndex + scheme.Rows
+	if grid := table.sheet.Properties.GridProperties; grid != nil && grid.RowCount > lastRow {
+		lastRow = grid.RowCount
+	}
+	if lastRow > tableDataStartRowIndex {
+		ranges, err := rangeString(scheme, tableDataStartRowIndex, lastRow-tableDataStartRowIndex)
+		if err != nil {
+			return err
+		}
+		if err := newClearValuesRequest(table.manager, table.spreadsheet().SpreadsheetId, ranges).Do(ctx); err != nil {
+			return err
+		}
+	}
+	req := newSpreadsheetValuesBatchUpdateRequest(table.manager, table.spreadsheet().SpreadsheetId, scheme.Name)
+	req.updateRows(scheme, false, 0)
+	return req.Do(ctx)
 }
 
 // Name name of the table
@@ -369,105 +636,225 @@ func (table *Table) spreadsheet() *sheets.Spreadsheet {
 
 // header Metadata of the table
 func (table *Table) header() *TableScheme {
+	table.mu.RLock()
+	defer table.mu.RUnlock()
 	return table.scheme
 }
 
+// writeLock Lock serializing writes to this table
+func (table *Table) writeLock() *tableWriteLock {
+	return table.manager.writeLock(table.spreadsheet().SpreadsheetId, table.sheetID())
+}
+
+// sync Reads table's metadata and index from the server
+func (table *Table) sync(ctx context.Context) error {
+	// writes finished after this are not seen
+	version := table.writeLock().currentVersion()
+	if _, err := table.updatedHeader(ctx); err != nil {
+		return err
+	}
+	if err := table.updateIndex(ctx); err != nil {
+		return err
+	}
+	table.mu.Lock()
+	table.syncedVersion = version
+	table.mu.Unlock()
+	return nil
+}
+
+// syncIfStale Syncs if others wrote to the table after the last sync.
+// Writers should hold `lock` so nobody writes after the check.
+func (table *Table) syncIfStale(ctx context.Context, lock *tableWriteLock) error {
+	table.mu.RLock()
+	synced := table.syncedVersion
+	table.mu.RUnlock()
+	if synced == lock.currentVersion() {
+		return nil
+	}
+	return table.sync(ctx)
+}
+
 // updatedHeader Reads table's metadata from the server and sync
-func (table *Table) updatedHeader() *TableScheme {
+func (table *Table) updatedHeader(ctx context.Context) (*TableScheme, error) {
 	// sync
-	table.manager.synchronizeFromGoogle(table.database)
+	if err := table.manager.synchronizeFromGoogle(ctx, table.database); err != nil {
+		return nil, err
+	}
 	tableName := table.Name()
 	tableCols := int64(len(table.header().Columns))
 
 	// 0행~2행, 모든 열을 읽는다
-	// 0행
+	// row 2 has numrows, numcols and constraints even if the table has fewer columns
 	req := newSpreadsheetValuesRequest(table.manager, table.spreadsheet().SpreadsheetId, tableName)
-	req.updateRange(tableName, 0, 0, 3, tableCols)
-	valueRange := req.Do()
+	if err := req.updateRange(tableName, 0, 0, 3, maximum64(tableCols, 3)); err != nil {
+		return nil, err
+	}
+	valueRange, err := req.Do(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	colnames := make([]string, len(valueRange.Values[0]))
-	for i := range colnames {
-		colnames[i] = valueRange.Values[0][i].(string)
+	metadata, err := parseTableScheme(tableName, valueRange.Values)
+	if err != nil {
+		return nil, err
 	}
-	// 1행
-	types := make([]reflect.Kind, len(valueRange.Values[1]))
-	for i := range colnames {
-		kindString := valueRange.Values[1][i].(string)
-		types[i] = primitiveStringToKind[kindString]
+	table.mu.Lock()
+	table.scheme = metadata
+	table.mu.Unlock()
+	return metadata, nil
+}
+
+// parseTableScheme Parses header rows of a table
+// Row 0: Column names
+// Row 1: Column datatype
+// Row 2: numrows, numcols, constraints(optional)
+func parseTableScheme(tableName string, values [][]interface{}) (*TableScheme, error) {
+	if len(values) < 3 || len(values[2]) < 2 {
+		return nil, corruptMetadata(tableName, "missing header rows")
+	}
+	rows, err := strconv.ParseInt(fmt.Sprint(values[2][0]), 10, 64)
+	if err != nil {
+		return nil, corruptMetadata(tableName, "numrows: %v", err)
+	}
+	cols, err := strconv.ParseInt(fmt.Sprint(values[2][1]), 10, 64)
+	if err != nil {
+		return nil, corruptMetadata(tableName, "numcols: %v", err)
+	}
+	if cols < 0 || int64(len(values[0])) < cols || int64(len(values[1])) < cols {
+		return nil, corruptMetadata(tableName, "expected %d columns", cols)
 	}
-	// 2행
-	rowsString, ok := valueRange.Values[2][0].(string)
-	var rows int64
-	if ok {
-		rows, _ = strconv.ParseInt(rowsString, 10, 64)
+
+	colnames := make([]string, cols)
+	types := make([]reflect.Kind, cols)
+	typeNames := make([]string, cols)
+	for i := range colnames {
+		colnames[i] = fmt.Sprint(values[0][i])
+		kindString := fmt.Sprint(values[1][i])
+		kind, ok := columnKind(kindString)
+		if !ok {
+			return nil, corruptMetadata(tableName, "unknown type %q of column %s", kindString, colnames[i])
+		}
+		types[i] = kind
+		typeNames[i] = kindString
 	}
 
 	var constraint = ""
-	if len(valueRange.Values[2]) >= 3 {
-		constraint = valueRange.Values[2][2].(string)
+	if len(values[2]) >= 3 {
+		constraint = fmt.Sprint(values[2][2])
+	}
+	constraints, err := newConstraintFromString(constraint)
+	if err != nil {
+		return nil, corruptMetadata(tableName, "constraints: %v", err)
 	}
-	metadata := &TableScheme{
+	return &TableScheme{
 		Name:        tableName,
 		Columns:     colnames,
+		ColumnMap:   newColumnMap(colnames),
 		Types:       types,
 		Rows:        rows,
-		Constraints: newConstraintFromString(constraint),
-	}
-	table.scheme = metadata
-	return metadata
+		Constraints: constraints,
+		typeNames:   typeNames,
+	}, nil
 }
 
 // value: a struct splitted with columns
 func (table *Table) hasIndexOf(value []interface{}) (bool, []int64) {
-	if table.scheme.Constraints == nil {
+	table.mu.RLock()
+	scheme, index := table.scheme, table.index
+	table.mu.RUnlock()
+	if scheme.Constraints == nil || index == nil {
 		return false, nil
 	}
 
 	// unique constraint
-	columns := table.scheme.Constraints.uniqueColumns
-	columnIndex := table.scheme.columnsToIndices(columns)
-	return table.index.hasIndex(value, columnIndex...)
+	columns := scheme.Constraints.uniqueColumns
+	columnIndex := scheme.columnsToIndices(columns)
+	if scheme.hasNullKey(value, columnIndex) {
+		return false, nil
+	}
+	return index.hasIndex(value, columnIndex...)
 }
 
-func (m *SheetManager) createColumnsFromStruct(table *sheets.Sheet, structInstance interface{}, constraint ...*Constraint) []*sheets.Request {
-	if table == nil {
-		return nil
+// dropDuplicateKeys Drops rows of `values` whose unique key equals that of an earlier row,
+// as rows already in the table are kept over new ones.
+// Rows with NULL in a unique column never conflict.
+func (table *Table) dropDuplicateKeys(values [][]interface{}) [][]interface{} {
+	table.mu.RLock()
+	scheme, index := table.scheme, table.index
+	table.mu.RUnlock()
+	if scheme.Constraints == nil || len(scheme.Constraints.uniqueColumns) == 0 || index == nil {
+		return values
 	}
-	fields := analyseStruct(structInstance)
-	if fields == nil {
-		return nil
+
+	columnIndex := scheme.columnsToIndices(scheme.Constraints.uniqueColumns)
+	seen := make(map[string]bool, len(values))
+	kept := values[:0:0]
+	for _, value := range values {
+		if !scheme.hasNullKey(value, columnIndex) {
+			key := index.hashcode(value, columnIndex...)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		kept = append(kept, value)
+	}
+	return kept
+}
+
+// createColumnsFromStruct Builds the request writing header rows of `structInstance`.
+// The sheet id of the range should be filled by the caller.
+func createColumnsFromStruct(structInstance interface{}, constraint ...*Constraint) ([]*sheets.Request, error) {
+	fields, err := analyseStruct(structInstance)
+	if err != nil {
+		return nil, err
 	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%w: %T has no column", ErrUnsupportedType, structInstance)
+	}
+	names := make([]string, len(fields))
+	types := make([]string, len(fields))
+	var uniqueColumns []string
+	for i := range fields {
+		names[i] = fields[i].cname
+		types[i] = fields[i].ctype
+		if fields[i].unique {
+			uniqueColumns = append(uniqueColumns, fields[i].cname)
+		}
+	}
+	// the given constraint wins over the tags
+	if len(constraint) == 0 && len(uniqueColumns) > 0 {
+		constraint = append(constraint, NewConstraint().SetUniqueColumns(uniqueColumns...))
+	}
+	return createColumns(names, types, constraint...)
+}
 
+// createColumns Builds the request writing header rows of columns `names` typed `types`
+func createColumns(names, types []string, constraint ...*Constraint) ([]*sheets.Request, error) {
 	requests := make([]*sheets.Request, 1)
 	requests[0] = &sheets.Request{}
 	requests[0].UpdateCells = &sheets.UpdateCellsRequest{}
 	requests[0].UpdateCells.Fields = "*"
 	requests[0].UpdateCells.Range = &sheets.GridRange{}
-	requests[0].UpdateCells.Range.SheetId = table.Properties.SheetId
 	requests[0].UpdateCells.Range.EndRowIndex = 3
 
 	data := make([]*sheets.RowData, 3)
 	// Row 0: Column names
 	data[0] = &sheets.RowData{}
-	data[0].Values = make([]*sheets.CellData, len(fields))
+	data[0].Values = make([]*sheets.CellData, len(names))
 	for i := range data[0].Values {
-		field := fields[i]
-		if _, ok := primitiveKindToString[field.ckind]; !ok {
-			// todo: log not a primitive field
-			return nil
-		}
 		data[0].Values[i] = &sheets.CellData{}
 		data[0].Values[i].UserEnteredValue = &sheets.ExtendedValue{}
-		data[0].Values[i].UserEnteredValue.StringValue = field.cname
+		data[0].Values[i].UserEnteredValue.StringValue = names[i]
 	}
 
 	// Row 1: Column datatype
 	data[1] = &sheets.RowData{}
-	data[1].Values = make([]*sheets.CellData, len(fields))
+	data[1].Values = make([]*sheets.CellData, len(types))
 	for i := range data[1].Values {
 		data[1].Values[i] = &sheets.CellData{}
 		data[1].Values[i].UserEnteredValue = &sheets.ExtendedValue{}
-		data[1].Values[i].UserEnteredValue.StringValue = fields[i].ctype
+		data[1].Values[i].UserEnteredValue.StringValue = types[i]
 	}
 
 	// Row 2, Col 0: How many data(numrows)
@@ -484,75 +871,82 @@ func (m *SheetManager) createColumnsFromStruct(table *sheets.Sheet, structInstan
 	data[2].Values[0].UserEnteredValue = &sheets.ExtendedValue{}
 	data[2].Values[0].UserEnteredValue.StringValue = "0"
 	data[2].Values[1].UserEnteredValue = &sheets.ExtendedValue{}
-	data[2].Values[1].UserEnteredValue.NumberValue = float64(len(fields))
+	data[2].Values[1].UserEnteredValue.NumberValue = float64(len(names))
 	if len(constraint) > 0 {
 		data[2].Values[2].UserEnteredValue = &sheets.ExtendedValue{}
 		constraintBytes, err := json.Marshal(constraint[0].toMap())
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 		data[2].Values[2].UserEnteredValue.StringValue = string(constraintBytes)
 	}
 
 	requests[0].UpdateCells.Rows = data
-	return requests
+	return requests, nil
 }
 
-func (table *Table) updateIndex() {
+func (table *Table) updateIndex(ctx context.Context) error {
 	// if no constraint, no index update
 	if table.header().Constraints == nil {
-		return
-	}
-	// if no index, create
-	if table.index == nil {
-		table.index = newTableIndex()
+		return nil
 	}
 
 	// call data
-	data, scheme := table.selectData(-1)
-	if scheme == nil {
-		panic("Metadata must not be nil when updating indices")
+	data, scheme, err := table.selectData(ctx, -1)
+	if err != nil {
+		return err
 	}
 
-	// build index
-	table.index.build(data, scheme)
+	// build index, replacing the old one
+	index := newTableIndex()
+	index.build(data, scheme)
+	table.mu.Lock()
+	table.index = index
+	table.mu.Unlock()
+	return nil
 }
 
 func (metadata *TableScheme) columnsToIndices(columns []string) []int64 {
-	if metadata.ColumnMap == nil {
-		tmp := make(map[string]int64)
-		for i, c := range metadata.Columns {
-			tmp[c] = int64(i)
-		}
-		metadata.ColumnMap = tmp
+	columnMap := metadata.ColumnMap
+	if columnMap == nil {
+		columnMap = newColumnMap(metadata.Columns)
 	}
 	result := make([]int64, len(columns))
 	for i, c := range columns {
-		result[i] = metadata.ColumnMap[c]
+		result[i] = columnMap[c]
 	}
 	return result
 }
 
+// newColumnMap Maps column names to their indices
+func newColumnMap(columns []string) map[string]int64 {
+	columnMap := make(map[string]int64)
+	for i, c := range columns {
+		columnMap[c] = int64(i)
+	}
+	return columnMap
+}
+
 func (metadata *TableScheme) fitsScheme(value interface{}) bool {
 	refl := reflect.ValueOf(value)
 	switch refl.Kind() {
 	case reflect.Slice:
 		if len(metadata.Types) != refl.Len() {
-			fmt.Printf("Invalid input: column count mismatching(table: %d, struct: %d)\n", len(metadata.Types), refl.Len())
 			return false
 		}
 		// real type 찾는 거 너무 힘드니 다음에 구현한다
 	case reflect.Struct:
-		if len(metadata.Types) != refl.NumField() {
+		fields, err := columnsOf(refl.Type())
+		if err != nil || len(metadata.Types) != len(fields) {
 			return false
 		}
-		for i := 0; i < refl.NumField(); i++ {
-			if refl.Field(i).Kind() != metadata.Types[i] {
+		for i := range fields {
+			if fields[i].ckind != metadata.Types[i] {
 				return false
 			}
 		}
 	default:
-		panic("Cannot handle this case")
+		return false
 	}
 	return true
 }
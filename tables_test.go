@@ -1,13 +1,18 @@
 package gosheet
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/rand"
+	"net/http"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/helloworldpark/gsheet-db-go/gsheettest"
 	"google.golang.org/api/sheets/v4"
 )
 
@@ -100,196 +105,514 @@ func describeTable(table *Table) {
 	fmt.Println("---------------------------------------")
 }
 
+// newTestDatabase Creates database "testdb" on the fake server
+func newTestDatabase(t testing.TB, manager *SheetManager) *Database {
+	db, err := manager.CreateDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
 // database: create, get, delete
 func TestCreateGetDeleteDatabase(t *testing.T) {
-	manager := NewSheetManager(jsonPath)
-	db := manager.CreateDatabase("Test First!2")
+	manager, server := newTestManager(t)
+	defer server.Close()
+
+	db, err := manager.CreateDatabase("Test First!2")
+	if err != nil {
+		t.Fatal(err)
+	}
 	fmt.Printf("------Created database %p------\n", db)
 	describeDatabase(db)
+	if title := db.Spreadsheet().Properties.Title; title != dbFileStart+"Test First!2" {
+		t.Fatalf("unexpected title %s", title)
+	}
 	sheetID := db.Spreadsheet().SpreadsheetId
-	manager.deleteSpreadsheet(sheetID)
+	if err := manager.deleteSpreadsheet(context.Background(), sheetID); err != nil {
+		t.Fatal(err)
+	}
 	fmt.Println("------Deleted database ", sheetID, "------")
+
+	if _, err := manager.FindDatabase("Test First!2"); !errors.Is(err, ErrDatabaseNotFound) {
+		t.Fatalf("expected ErrDatabaseNotFound, got %v", err)
+	}
 }
 
 // spreadsheet: get
 func TestGetSpreadsheetByID(t *testing.T) {
-	manager := NewSheetManager(jsonPath)
-	sheetID := "1QMUZpqgBCHWEFQ7YEWBwmWwkrtU8yNOTJD0srqt4aFc"
-	spreadsheet := manager.getSpreadsheet(sheetID)
+	manager, server := newTestManager(t)
+	defer server.Close()
+
+	db := newTestDatabase(t, manager)
+	sheetID := db.Spreadsheet().SpreadsheetId
+	spreadsheet, err := manager.getSpreadsheet(context.Background(), sheetID)
+	if err != nil {
+		t.Fatal(err)
+	}
 	describeSpreadsheet(spreadsheet)
+	if spreadsheet.SpreadsheetId != sheetID || spreadsheet.Properties.Title != dbFileStart+"testdb" {
+		t.Fatalf("unexpected spreadsheet %s %s", spreadsheet.SpreadsheetId, spreadsheet.Properties.Title)
+	}
+
+	if _, err := manager.getSpreadsheet(context.Background(), "missing"); err == nil {
+		t.Fatal("expected error for a missing spreadsheet")
+	}
+}
+
+// database: open by id
+func TestOpenDatabase(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+
+	created := newTestDatabase(t, manager)
+	if _, err := manager.CreateDatabase("other"); err != nil {
+		t.Fatal(err)
+	}
+	manager.ResetUsage()
+	db, err := manager.OpenDatabase(created.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.ID() != created.ID() || db.Spreadsheet().Properties.Title != dbFileStart+"testdb" {
+		t.Fatalf("unexpected database %s %s", db.ID(), db.Spreadsheet().Properties.Title)
+	}
+	if n := manager.Usage().Total(); n != 1 {
+		t.Fatalf("expected a single call, got %d", n)
+	}
+
+	if _, err := manager.OpenDatabase("missing"); !errors.Is(err, ErrDatabaseNotFound) {
+		t.Fatalf("expected ErrDatabaseNotFound, got %v", err)
+	}
+	plain, err := manager.createSpreadsheet(context.Background(), "not a database", manager.databaseOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.OpenDatabase(plain.SpreadsheetId); !errors.Is(err, ErrDatabaseNotFound) {
+		t.Fatalf("expected ErrDatabaseNotFound, got %v", err)
+	}
 }
 
 // spreadsheet: list
 func TestListSpreadsheet(t *testing.T) {
-	manager := NewSheetManager(jsonPath)
-	sheets := manager.listSpreadsheets()
+	manager, server := newTestManager(t)
+	defer server.Close()
+
+	for _, title := range []string{"testdb", "testdb2"} {
+		if _, err := manager.CreateDatabase(title); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := manager.createSpreadsheet(context.Background(), "not database_file_x", manager.databaseOptions); err != nil {
+		t.Fatal(err)
+	}
+	sheets, err := manager.listSpreadsheets(context.Background(), "", false, driveFileFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sheets) != 2 {
+		t.Fatalf("expected 2 spreadsheets, got %d", len(sheets))
+	}
 	fmt.Println("------Listing sheets------")
 	for _, s := range sheets {
-		sheet := manager.getSpreadsheet(s)
+		sheet, err := manager.getSpreadsheet(context.Background(), s.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
 		describeSpreadsheet(sheet)
 	}
 }
 
+// spreadsheet: list every page
+func TestListSpreadsheetPages(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+	defer func(size int) { driveListPageSize = size }(driveListPageSize)
+	driveListPageSize = 2
+
+	titles := []string{"a", "b", "c", "d", "it's \\ quoted"}
+	for _, title := range titles {
+		if _, err := manager.CreateDatabase(title); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server.ResetRequests()
+	files, err := manager.listSpreadsheets(context.Background(), "", false, driveFileFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != len(titles) {
+		t.Fatalf("expected %d spreadsheets, got %d", len(titles), len(files))
+	}
+	if n := len(server.Requests()); n != 3 {
+		t.Fatalf("expected 3 pages, got %d", n)
+	}
+	if fields := server.Requests()[0].Query.Get("fields"); fields != "nextPageToken,files(id,name)" {
+		t.Fatalf("unexpected fields %q", fields)
+	}
+
+	// exact title on the server side, escaped
+	server.ResetRequests()
+	db, err := manager.FindDatabase("it's \\ quoted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Spreadsheet().Properties.Title != dbFileStart+"it's \\ quoted" {
+		t.Fatalf("unexpected database %s", db.Spreadsheet().Properties.Title)
+	}
+	if n := len(server.Requests()); n != 2 {
+		t.Fatalf("expected a search and a get, got %d requests", n)
+	}
+	if _, err := manager.FindDatabase("it"); !errors.Is(err, ErrDatabaseNotFound) {
+		t.Fatalf("expected ErrDatabaseNotFound, got %v", err)
+	}
+}
+
+// database: list
+func TestListDatabases(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+
+	before := time.Now().Add(-time.Second)
+	users, err := manager.CreateDatabase("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	findOrCreateTable(t, users, TestStructMeme{})
+	findOrCreateTable(t, users, TestStructSmall{})
+	if _, err := manager.CreateDatabase("accounts"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.createSpreadsheet(context.Background(), "not a database", manager.databaseOptions); err != nil {
+		t.Fatal(err)
+	}
+
+	infos, err := manager.ListDatabases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 || infos[0].Title != "accounts" || infos[1].Title != "users" {
+		t.Fatalf("unexpected databases %+v", infos)
+	}
+	info := infos[1]
+	if info.ID != users.ID() || info.Owner != gsheettest.Owner {
+		t.Fatalf("unexpected database %+v", info)
+	}
+	if info.CreatedTime.Before(before) || info.ModifiedTime.Before(info.CreatedTime) {
+		t.Fatalf("unexpected times %v %v", info.CreatedTime, info.ModifiedTime)
+	}
+	if !reflect.DeepEqual(info.Tables, []string{"TestStructMeme", "TestStructSmall"}) {
+		t.Fatalf("unexpected tables %v", info.Tables)
+	}
+	if len(infos[0].Tables) != 0 {
+		t.Fatalf("unexpected tables %v", infos[0].Tables)
+	}
+}
+
 // spreadsheet: find
 func TestFindSpreadsheet(t *testing.T) {
-	manager := NewSheetManager(jsonPath)
-	sheet, _ := manager.findSpreadsheet("Test First!")
+	manager, server := newTestManager(t)
+	defer server.Close()
+
+	if _, err := manager.CreateDatabase("Test First!"); err != nil {
+		t.Fatal(err)
+	}
+	sheet, err := manager.findSpreadsheet(context.Background(), dbFileStart+"Test First!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sheet == nil {
+		t.Fatal("sheet is nil")
+	}
 	fmt.Println("------Listing sheet------")
 	describeSpreadsheet(sheet)
+
+	sheet, err = manager.findSpreadsheet(context.Background(), "Test First!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sheet != nil {
+		t.Fatalf("unexpected spreadsheet %s", sheet.Properties.Title)
+	}
 }
 
 // spreadsheet: find + delete
 func TestDeleteDatabase(t *testing.T) {
-	manager := NewSheetManager(jsonPath)
-	sheet, _ := manager.findSpreadsheet(dbFileStart + "testdb")
+	manager, server := newTestManager(t)
+	defer server.Close()
+
+	newTestDatabase(t, manager)
+	sheet, err := manager.findSpreadsheet(context.Background(), dbFileStart+"testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sheet == nil {
+		t.Fatal("sheet is nil")
+	}
 	fmt.Println("------Find sheet------")
 	describeSpreadsheet(sheet)
 	sheetID := sheet.SpreadsheetId
-	if manager.deleteSpreadsheet(sheet.SpreadsheetId) {
-		fmt.Println("------Delete sheet ", sheetID, "------")
+	if err := manager.deleteSpreadsheet(context.Background(), sheet.SpreadsheetId); err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println("------Delete sheet ", sheetID, "------")
+
+	sheet, err = manager.findSpreadsheet(context.Background(), dbFileStart+"testdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sheet != nil {
+		t.Fatal("deleted sheet is still found")
 	}
 }
 
 // db: create, no index
 func TestCreateDatabase(t *testing.T) {
-	manager := NewSheetManager(jsonPath)
-	database := manager.CreateDatabase("testdb")
-	if database == nil {
-		t.Fatal("database nil")
+	manager, server := newTestManager(t)
+	defer server.Close()
+
+	database, err := manager.CreateDatabase("testdb")
+	if err != nil {
+		t.Fatal(err)
 	}
 	fmt.Println("------Create sheet------")
 	describeDatabase(database)
+
+	if _, err := manager.CreateDatabase("testdb"); !errors.Is(err, ErrDatabaseExists) {
+		t.Fatalf("expected ErrDatabaseExists, got %v", err)
+	}
 }
 
 // table: create, index
 func TestCreateTableWithIndex(t *testing.T) {
-	manager := NewSheetManager(jsonPath)
-	db := manager.FindDatabase("testdb")
-	if db == nil {
-		t.Fatalf("database %s is nil", "testdb")
-	}
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
 
 	constraint := NewConstraint()
 	constraint.SetUniqueColumns("Name1", "Name2")
-	table := db.createTable(TestStructMeme{}, constraint)
+	table, err := db.createTable(context.Background(), TestStructMeme{}, constraint)
+	if err != nil {
+		t.Fatal(err)
+	}
 	fmt.Printf("DB: %s Table %s[%d] created\n", db.spreadsheet.SpreadsheetId, table.Name(), table.sheetID())
 
 	tableMeta := table.header()
 	fmt.Printf("Metadata: \n%+v\n", *tableMeta)
+	if !reflect.DeepEqual(tableMeta.Columns, []string{"Name1", "Name2", "Name3", "Name4", "Name5", "Name6"}) {
+		t.Fatalf("unexpected columns %v", tableMeta.Columns)
+	}
+	if tableMeta.Constraints == nil || !reflect.DeepEqual(tableMeta.Constraints.uniqueColumns, []string{"Name1", "Name2"}) {
+		t.Fatalf("unexpected constraints %+v", tableMeta.Constraints)
+	}
 
 	for i, v := range table.index.uniqueIndex {
 		fmt.Printf("Idx[%s] %v\n", i, v)
 	}
+
+	if _, err := db.createTable(context.Background(), TestStructMeme{}); !errors.Is(err, ErrTableExists) {
+		t.Fatalf("expected ErrTableExists, got %v", err)
+	}
 }
 
 // table: list
 func TestListTables(t *testing.T) {
-	manager := NewSheetManager(jsonPath)
-	database := manager.FindDatabase("testdb")
-	database.Manager().synchronizeFromGoogle(database)
-	tables := database.ListTables()
+	manager, server := newTestManager(t)
+	defer server.Close()
+	database := newTestDatabase(t, manager)
+
+	findOrCreateTable(t, database, TestStructMeme{})
+	findOrCreateTable(t, database, TestStructSmall{})
+
+	tables, err := database.ListTables()
+	if err != nil {
+		t.Fatal(err)
+	}
 	for i := range tables {
 		describeTable(tables[i])
 	}
+	if len(tables) != 2 {
+		t.Fatalf("expected 2 tables, got %d", len(tables))
+	}
+}
+
+// db: sync metadata only
+func TestSynchronizeMetadataOnly(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+	database := newTestDatabase(t, manager)
+
+	table := findOrCreateTable(t, database, TestStructMeme{})
+	if err := table.UpsertIf(memes(5), true); err != nil {
+		t.Fatal(err)
+	}
+	server.ResetRequests()
+	tables, err := database.ListTables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 1 || tables[0].Name() != "TestStructMeme" {
+		t.Fatalf("unexpected tables %v", tables)
+	}
+	if tables[0].header().Rows != 5 {
+		t.Fatalf("expected 5 rows, got %d", tables[0].header().Rows)
+	}
+
+	for _, req := range server.Requests() {
+		if req.Method != "GET" || strings.Contains(req.Path, "/values/") {
+			continue
+		}
+		if len(req.Query.Get("includeGridData")) > 0 || req.Query.Get("fields") != spreadsheetMetadataFields {
+			t.Fatalf("spreadsheet fetched with %v", req.Query)
+		}
+	}
+	for _, sheet := range database.Sheets() {
+		if sheet.Data != nil || sheet.Properties == nil || len(sheet.Properties.Title) == 0 {
+			t.Fatalf("unexpected sheet %+v", sheet)
+		}
+	}
+	if err := table.Drop(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(database.Sheets()); n != 1 {
+		t.Fatalf("expected only the default sheet after drop, got %d", n)
+	}
 }
 
 // table: drop
 func TestDropTable(t *testing.T) {
-	manager := NewSheetManager(jsonPath)
-	database := manager.FindDatabase("testdb")
-	if database == nil {
-		t.Fatal("database is nil")
-	}
+	manager, server := newTestManager(t)
+	defer server.Close()
+	database := newTestDatabase(t, manager)
+
 	describeDatabase(database)
-	table := database.FindTable(TestStructMeme{})
-	if table == nil {
-		t.Fatal("table deleted")
+	findOrCreateTable(t, database, TestStructMeme{})
+	table, err := database.FindTable(TestStructMeme{})
+	if err != nil {
+		t.Fatal(err)
 	}
 
 	tableName := table.Name()
-	dropped := table.Drop()
-	if dropped {
-		fmt.Println("Dropped table = ", tableName)
-	} else {
-		fmt.Println("Failed drop table = ", tableName)
+	if err := table.Drop(); err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println("Dropped table = ", tableName)
+
+	if _, err := database.FindTable(TestStructMeme{}); !errors.Is(err, ErrTableNotFound) {
+		t.Fatalf("expected ErrTableNotFound, got %v", err)
 	}
 
-	tables := database.ListTables()
+	tables, err := database.ListTables()
+	if err != nil {
+		t.Fatal(err)
+	}
 	for i := range tables {
 		describeTable(tables[i])
 	}
+	if len(tables) != 0 {
+		t.Fatalf("expected no tables, got %d", len(tables))
+	}
 }
 
 // table: reset(convenicence, delete + create)
 func TestResetTable(t *testing.T) {
-	manager := NewSheetManager(jsonPath)
-	database := manager.FindDatabase("testdb")
-	if database == nil {
-		return
-	}
+	manager, server := newTestManager(t)
+	defer server.Close()
+	database := newTestDatabase(t, manager)
 
 	describeDatabase(database)
 
-	table := database.FindTable(TestStructMeme{})
-	if table == nil {
-		t.Fatal("Table is nil")
+	table := findOrCreateTable(t, database, TestStructMeme{})
+	if err := table.UpsertIf([]interface{}{TestStructMeme{Name5: "before reset"}}, true); err != nil {
+		t.Fatal(err)
 	}
 	tableName := table.Name()
-	deleted := table.Drop()
-	if deleted {
-		fmt.Println("Deleted Table ", tableName)
-	} else {
-		fmt.Println("Failed drop Table ", tableName)
+	if err := table.Drop(); err != nil {
+		t.Fatal(err)
 	}
+	fmt.Println("Deleted Table ", tableName)
 
-	table = database.createTable(TestStructMeme{})
+	table, err := database.createTable(context.Background(), TestStructMeme{})
+	if err != nil {
+		t.Fatal(err)
+	}
 	fmt.Println("Table created")
 	describeTable(table)
-}
-
-// table: read
-func TestReadTable(t *testing.T) {
-	manager := NewSheetManager(jsonPath)
-	db := manager.FindDatabase("testdb")
-	if db == nil {
-		t.Fatalf("Sheet %s is nil", "testdb")
+	if table.header().Rows != 0 {
+		t.Fatalf("reset table has %d rows", table.header().Rows)
 	}
+}
 
-	// Find or make table
-	table := db.FindTable(TestStructMeme{})
-	if table == nil {
-		table = db.createTable(TestStructMeme{})
+// findOrCreateTable Finds table of `scheme`, or creates it if not existing
+func findOrCreateTable(t *testing.T, db *Database, scheme interface{}, constraint ...*Constraint) *Table {
+	table, err := db.FindTable(scheme)
+	if errors.Is(err, ErrTableNotFound) {
+		table, err = db.createTable(context.Background(), scheme, constraint...)
+		if err != nil {
+			t.Fatal(err)
+		}
 		fmt.Println("Table created\n", table.Name(), table.sheetID())
+	} else if err != nil {
+		t.Fatal(err)
 	} else {
 		fmt.Println("Table found\n", table.Name(), table.sheetID())
 	}
+	return table
+}
+
+// memes Rows with known values. Name1 is negative for odd i, Name6 is true for even i.
+func memes(n int) []interface{} {
+	var values []interface{}
+	for i := 0; i < n; i++ {
+		name1 := int16(i + 1)
+		if i%2 == 1 {
+			name1 = -name1
+		}
+		values = append(values, TestStructMeme{
+			Name1: name1,
+			Name2: int32(i * 100),
+			Name3: i * 1000,
+			Name4: float64(i) + 0.5,
+			Name5: fmt.Sprintf("Perfume%d", i),
+			Name6: i%2 == 0,
+		})
+	}
+	return values
+}
+
+// table: read
+func TestReadTable(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
+
+	// Find or make table
+	table := findOrCreateTable(t, db, TestStructMeme{})
 	describeTable(table)
 
-	tableValue, _ := table.selectData(-1)
+	tableValue, _, err := table.selectData(context.Background(), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
 	for i := 0; i < len(tableValue); i++ {
 		for j := 0; j < len(tableValue[i]); j++ {
 			fmt.Printf("V[%d][%d] = %v", i, j, tableValue[i][j])
 		}
 		fmt.Printf("\n")
 	}
+	if len(tableValue) != 0 {
+		t.Fatalf("new table has %d rows", len(tableValue))
+	}
 }
 
 // table: read, write
 func TestReadAndWriteTable(t *testing.T) {
-	manager := NewSheetManager(jsonPath)
-	db := manager.FindDatabase("testdb")
-	if db == nil {
-		t.Fatalf("Sheet %s is nil", "testdb")
-	}
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
 
 	// Find or make table
-	table := db.FindTable(TestStructMeme{})
-	if table == nil {
-		table = db.createTable(TestStructMeme{})
-		fmt.Println("Table created\n", table.Name(), table.sheetID())
-	} else {
-		fmt.Println("Table found\n", table.Name(), table.sheetID())
-	}
+	table := findOrCreateTable(t, db, TestStructMeme{})
 	describeTable(table)
 
 	// Write to table
@@ -315,39 +638,51 @@ func TestReadAndWriteTable(t *testing.T) {
 	}
 	fmt.Println("Table before")
 	describeTable(table)
-	didSuccess := table.upsertIf(values, true)
-	if didSuccess {
-		fmt.Printf("Table %s[%d] Success Write %d Data\n", table.Name(), table.sheetID(), len(values))
-	} else {
-		fmt.Printf("Table %s[%d] Failed  Write %d Data\n", table.Name(), table.sheetID(), len(values))
+	if err := table.upsertIf(context.Background(), values, true); err != nil {
+		t.Fatalf("Table %s[%d] Failed  Write %d Data: %v", table.Name(), table.sheetID(), len(values), err)
 	}
+	fmt.Printf("Table %s[%d] Success Write %d Data\n", table.Name(), table.sheetID(), len(values))
 	fmt.Println("Table after")
 	describeTable(table)
 
-	tableValue, _ := table.selectData(-1)
+	tableValue, _, err := table.selectData(context.Background(), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
 	for i := 0; i < len(tableValue); i++ {
 		for j := 0; j < len(tableValue[i]); j++ {
 			fmt.Printf("V[%d][%d] = %v ", i, j, tableValue[i][j])
 		}
 		fmt.Printf("\n")
 	}
+	if len(tableValue) != len(values) {
+		t.Fatalf("expected %d rows, got %d", len(values), len(tableValue))
+	}
+	for i := range tableValue {
+		if tableValue[i][4] != fmt.Sprintf("Perfume%d", i) {
+			t.Fatalf("unexpected row %d: %v", i, tableValue[i])
+		}
+	}
+
+	// appending keeps the rows written before
+	if err := table.upsertIf(context.Background(), memes(3), true); err != nil {
+		t.Fatal(err)
+	}
+	if table.header().Rows != int64(len(values)+3) {
+		t.Fatalf("expected %d rows, got %d", len(values)+3, table.header().Rows)
+	}
 }
 
 // table: read, filter
 func TestReadTableWithFilter(t *testing.T) {
-	manager := NewSheetManager(jsonPath)
-	db := manager.FindDatabase("testdb")
-	if db == nil {
-		t.Fatalf("Sheet %s is nil", "testdb")
-	}
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
 
 	// Find or make table
-	table := db.FindTable(TestStructMeme{})
-	if table == nil {
-		table = db.createTable(TestStructMeme{})
-		fmt.Println("Table created\n", table.Name(), table.sheetID())
-	} else {
-		fmt.Println("Table found\n", table.Name(), table.sheetID())
+	table := findOrCreateTable(t, db, TestStructMeme{})
+	if err := table.UpsertIf(memes(8), true); err != nil {
+		t.Fatal(err)
 	}
 	describeTable(table)
 
@@ -357,37 +692,39 @@ func TestReadTableWithFilter(t *testing.T) {
 	filter2 := func(field interface{}) bool {
 		v, _ := field.(string)
 		v2, _ := strconv.ParseInt(v, 10, 16)
-		return int16(v2) < 0
+		return int16(v2) > 2
 	}
 
 	filterMap := make(map[int]Predicate)
 	filterMap[5] = filter
 	filterMap[0] = filter2
 
-	tableValue, _ := table.selectAndFilter(filterMap)
+	tableValue, _, err := table.selectAndFilter(context.Background(), filterMap)
+	if err != nil {
+		t.Fatal(err)
+	}
 	for i := 0; i < len(tableValue); i++ {
 		for j := 0; j < len(tableValue[i]); j++ {
 			fmt.Printf("V[%d][%d] = %v ", i, j, tableValue[i][j])
 		}
 		fmt.Printf("\n")
 	}
+	// Name1: 3, 5, 7
+	if len(tableValue) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(tableValue))
+	}
 }
 
 // table: delete row
 func TestDeleteRow(t *testing.T) {
-	manager := NewSheetManager(jsonPath)
-	db := manager.FindDatabase("testdb")
-	if db == nil {
-		t.Fatalf("Sheet %s is nil", "testdb")
-	}
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
 
 	// Find or make table
-	table := db.FindTable(TestStructMeme{})
-	if table == nil {
-		table = db.createTable(TestStructMeme{})
-		fmt.Println("Table created\n", table.Name(), table.sheetID())
-	} else {
-		fmt.Println("Table found\n", table.Name(), table.sheetID())
+	table := findOrCreateTable(t, db, TestStructMeme{})
+	if err := table.UpsertIf(memes(6), true); err != nil {
+		t.Fatal(err)
 	}
 	describeTable(table)
 
@@ -411,13 +748,22 @@ func TestDeleteRow(t *testing.T) {
 		return p1
 	}
 
-	deletedIndex := table.delete(predicate)
+	deletedIndex, err := table.delete(context.Background(), predicate)
+	if err != nil {
+		t.Fatal(err)
+	}
 	for i := 0; i < len(deletedIndex); i++ {
 		fmt.Println("Deleted: ", deletedIndex[i])
 	}
 	fmt.Println("------3")
+	if !reflect.DeepEqual(deletedIndex, []int64{1, 3, 5}) {
+		t.Fatalf("unexpected deleted rows %v", deletedIndex)
+	}
 
-	tableValue, _ := table.selectData(-1)
+	tableValue, _, err := table.selectData(context.Background(), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
 	for i := 0; i < len(tableValue); i++ {
 		for j := 0; j < len(tableValue[i]); j++ {
 			fmt.Printf("V[%d][%d] = %v ", i, j, tableValue[i][j])
@@ -425,25 +771,67 @@ func TestDeleteRow(t *testing.T) {
 		fmt.Printf("\n")
 	}
 	fmt.Println("------4")
+	// stale rows below the table must not be read back
+	if len(tableValue) != 3 || table.header().Rows != 3 {
+		t.Fatalf("expected 3 rows, got %d (header %d)", len(tableValue), table.header().Rows)
+	}
+	for i := range tableValue {
+		if filter0(tableValue[i][0]) {
+			t.Fatalf("row %d should have been deleted: %v", i, tableValue[i])
+		}
+		if len(tableValue[i]) != len(table.header().Columns) {
+			t.Fatalf("row %d has %d columns", i, len(tableValue[i]))
+		}
+	}
+}
+
+func TestDeleteRowWithConstraint(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
+
+	table := findOrCreateTable(t, db, TestStructMeme{}, NewConstraint().SetUniqueColumns("Name5"))
+	if err := table.UpsertIf(memes(4), true); err != nil {
+		t.Fatal(err)
+	}
+	deletedIndex, err := table.Delete(func(row []interface{}) bool {
+		return row[4] == "Perfume1"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(deletedIndex, []int64{1}) {
+		t.Fatalf("unexpected deleted rows %v", deletedIndex)
+	}
+	rows, _, err := table.Select(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []interface{}
+	for _, row := range rows {
+		names = append(names, row[4])
+	}
+	if !reflect.DeepEqual(names, []interface{}{"Perfume0", "Perfume2", "Perfume3"}) {
+		t.Fatalf("expected the kept rows, got %v", names)
+	}
+	// the deleted key can be inserted again
+	if err := table.UpsertIf(memes(2), true); err != nil {
+		t.Fatal(err)
+	}
+	if rows := table.header().Rows; rows != 4 {
+		t.Fatalf("expected 4 rows, got %d", rows)
+	}
 }
 
 // table: create + constraint
 func TestConstraintTable(t *testing.T) {
-	manager := NewSheetManager(jsonPath)
-	db := manager.FindDatabase("testdb")
-	if db == nil {
-		t.Fatalf("database %s is nil", "testdb")
-	}
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
 
-	table := db.FindTable(TestStructSmall{})
-	if table == nil {
-		constraint := NewConstraint()
-		constraint.SetUniqueColumns("Yes", "Name")
-		table = db.createTable(TestStructSmall{}, constraint)
-		fmt.Println("Table created\n", table.Name(), table.sheetID())
-	} else {
-		fmt.Println("Table found\n", table.Name(), table.sheetID())
-	}
+	constraint := NewConstraint()
+	constraint.SetUniqueColumns("Yes", "Name")
+	table := findOrCreateTable(t, db, TestStructSmall{}, constraint)
 	describeTable(table)
 
 	bucket := make([]interface{}, 5)
@@ -467,9 +855,20 @@ func TestConstraintTable(t *testing.T) {
 		Yes:  false,
 		Name: "scdef",
 	}
-	table.upsertIf(bucket, true)
+	if err := table.upsertIf(context.Background(), bucket, true); err != nil {
+		t.Fatal(err)
+	}
+	rows := table.header().Rows
+
+	// rows already in the table are not written again
+	if err := table.upsertIf(context.Background(), bucket, true); err != nil {
+		t.Fatal(err)
+	}
 
-	data, tableMeta := table.selectData(-1)
+	data, tableMeta, err := table.selectData(context.Background(), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
 	fmt.Println("-----------------------------")
 	fmt.Printf("Table name: %s Rows: %d\n", tableMeta.Name, tableMeta.Rows)
 	fmt.Printf("      %v\n      %v\n", tableMeta.Types, tableMeta.Columns)
@@ -480,25 +879,20 @@ func TestConstraintTable(t *testing.T) {
 		}
 		fmt.Println()
 	}
+	if tableMeta.Rows != rows {
+		t.Fatalf("duplicated rows were written: %d -> %d", rows, tableMeta.Rows)
+	}
 }
 
 // table: validation
 func TestInvalidSchemeValue(t *testing.T) {
-	manager := NewSheetManager(jsonPath)
-	db := manager.FindDatabase("testdb")
-	if db == nil {
-		t.Fatalf("database %s is nil", "testdb")
-	}
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
 
-	table := db.FindTable(TestStructSmall{})
-	if table == nil {
-		constraint := NewConstraint()
-		constraint.SetUniqueColumns("Yes", "Name")
-		table = db.createTable(TestStructSmall{}, constraint)
-		fmt.Println("Table created\n", table.Name(), table.sheetID())
-	} else {
-		fmt.Println("Table found\n", table.Name(), table.sheetID())
-	}
+	constraint := NewConstraint()
+	constraint.SetUniqueColumns("Yes", "Name")
+	table := findOrCreateTable(t, db, TestStructSmall{}, constraint)
 	describeTable(table)
 
 	bucket := make([]interface{}, 5)
@@ -510,13 +904,18 @@ func TestInvalidSchemeValue(t *testing.T) {
 		testing:  "ssdfod",
 	}
 
-	table.upsertIf(bucket, true)
+	if err := table.upsertIf(context.Background(), bucket, true); !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("expected ErrSchemaMismatch, got %v", err)
+	}
 
 	for i, v := range table.index.uniqueIndex {
 		fmt.Printf("----Idx[%s] %v\n", i, v)
 	}
 
-	data, tableMeta := table.selectData(-1)
+	data, tableMeta, err := table.selectData(context.Background(), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
 	fmt.Println("-----------------------------")
 	fmt.Printf("Table name: %s Rows: %d\n", tableMeta.Name, tableMeta.Rows)
 	fmt.Printf("      %v\n      %v\n", tableMeta.Types, tableMeta.Columns)
@@ -527,4 +926,184 @@ func TestInvalidSchemeValue(t *testing.T) {
 		}
 		fmt.Println()
 	}
+	if tableMeta.Rows != 0 {
+		t.Fatalf("invalid values were written: %d rows", tableMeta.Rows)
+	}
+}
+
+func TestTaggedTable(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
+
+	table, err := db.CreateTable(TestStructTagged{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheme := table.header()
+	if !reflect.DeepEqual(scheme.Columns, []string{"id", "display_name", "Note", "-"}) {
+		t.Fatalf("unexpected columns %v", scheme.Columns)
+	}
+	if scheme.Constraints == nil || !reflect.DeepEqual(scheme.Constraints.uniqueColumns, []string{"id"}) {
+		t.Fatalf("expected unique column id, got %+v", scheme.Constraints)
+	}
+
+	values := []interface{}{
+		TestStructTagged{ID: 1, Name: "first", Ignored: []byte("x")},
+		TestStructTagged{ID: 2, Name: "second", Note: "note"},
+		// duplicate id
+		TestStructTagged{ID: 1, Name: "third"},
+	}
+	if err := table.UpsertIf(values[:2], true); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.UpsertIf(values[2:], true); err != nil {
+		t.Fatal(err)
+	}
+	rows, _, err := table.Select(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]interface{}{{"1", "first"}, {"2", "second", "note"}}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Fatalf("expected %v, got %v", expected, rows)
+	}
+}
+
+type TestStructTaggedCode struct {
+	Name string
+	Code int64 `gsheet:",unique,omitempty"`
+}
+
+func TestTaggedUniqueOmitempty(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
+
+	table, err := db.CreateTable(TestStructTaggedCode{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// zero codes are blank cells at the end of the row, and the same code
+	for _, name := range []string{"first", "second"} {
+		if err := table.UpsertIf([]interface{}{TestStructTaggedCode{Name: name}}, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := table.UpsertIf([]interface{}{TestStructTaggedCode{Name: "third", Code: 3}}, true); err != nil {
+		t.Fatal(err)
+	}
+	rows, _, err := table.Select(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]interface{}{{"first"}, {"third", "3"}}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Fatalf("expected %v, got %v", expected, rows)
+	}
+}
+
+type TestStructTaggedKey struct {
+	K string `gsheet:",unique"`
+	V int
+}
+
+func TestUniqueKeysInBatch(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
+
+	table, err := db.CreateTable(TestStructTaggedKey{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the first row of a key wins within the batch as over the table
+	batch := []interface{}{
+		TestStructTaggedKey{"a", 1},
+		TestStructTaggedKey{"b", 2},
+		TestStructTaggedKey{"a", 3},
+	}
+	if err := table.UpsertIf(batch, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.UpsertIf([]interface{}{TestStructTaggedKey{"b", 4}, TestStructTaggedKey{"c", 5}, TestStructTaggedKey{"c", 6}}, true); err != nil {
+		t.Fatal(err)
+	}
+	rows, _, err := table.Select(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]interface{}{{"a", "1"}, {"b", "2"}, {"c", "5"}}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Fatalf("expected %v, got %v", expected, rows)
+	}
+}
+
+type TestStructNoColumn struct {
+	Ignored []byte `gsheet:"-"`
+	hidden  string
+}
+
+func TestCreateTableWithoutColumns(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
+
+	sheets := len(db.Sheets())
+	if _, err := db.CreateTable(TestStructNoColumn{}); !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("expected ErrUnsupportedType, got %v", err)
+	}
+	if _, err := db.CreateTable(struct{}{}); !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("expected ErrUnsupportedType, got %v", err)
+	}
+	for _, scheme := range []interface{}{nil, 1, &TestStructSmall{}} {
+		if _, err := db.CreateTable(scheme); !errors.Is(err, ErrUnsupportedType) {
+			t.Fatalf("expected ErrUnsupportedType creating %T, got %v", scheme, err)
+		}
+		if _, err := db.FindTable(scheme); !errors.Is(err, ErrUnsupportedType) {
+			t.Fatalf("expected ErrUnsupportedType finding %T, got %v", scheme, err)
+		}
+	}
+	if len(db.Sheets()) != sheets {
+		t.Fatalf("expected %d sheets, got %d", sheets, len(db.Sheets()))
+	}
+}
+
+func TestCreateTableFailure(t *testing.T) {
+	manager, server := newTestManager(t)
+	defer server.Close()
+	db := newTestDatabase(t, manager)
+	findOrCreateTable(t, db, TestStructSmall{})
+
+	// the sheet and its header are added together or not at all
+	count := len(db.Sheets())
+	server.InjectFault(1, gsheettest.Fault{Status: http.StatusServiceUnavailable, Method: http.MethodPost})
+	if _, err := db.CreateTable(TestStructMeme{}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := db.ListTables(); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.Sheets()) != count {
+		t.Fatalf("expected %d sheets, got %d", count, len(db.Sheets()))
+	}
+
+	// a sheet without header rows does not break other tables
+	request := &sheets.Request{AddSheet: &sheets.AddSheetRequest{Properties: &sheets.SheetProperties{Title: "TestStructMeme"}}}
+	if _, _, err := db.batchUpdate(context.Background(), []*sheets.Request{request}); err != nil {
+		t.Fatal(err)
+	}
+	tables, err := db.ListTables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 1 || tables[0].Name() != "TestStructSmall" {
+		t.Fatalf("expected only TestStructSmall, got %d tables", len(tables))
+	}
+	if _, err := db.FindTable(TestStructSmall{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.FindTable(TestStructMeme{}); !errors.Is(err, ErrCorruptMetadata) {
+		t.Fatalf("expected ErrCorruptMetadata, got %v", err)
+	}
 }
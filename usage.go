@@ -0,0 +1,106 @@
+package gosheet
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// RequestCount Number of HTTP requests sent to Google, retries included
+type RequestCount struct {
+	Reads  int64
+	Writes int64
+}
+
+// Total Reads and writes together
+func (c RequestCount) Total() int64 {
+	return c.Reads + c.Writes
+}
+
+func (c *RequestCount) add(write bool) {
+	if write {
+		c.Writes++
+	} else {
+		c.Reads++
+	}
+}
+
+// Usage Requests sent by a SheetManager since created or ResetUsage
+type Usage struct {
+	RequestCount
+	// Operations Requests by the method sending them, e.g. "Table.UpsertIf".
+	// Calls of both UpsertIf and UpsertIfContext are counted as "Table.UpsertIf".
+	Operations map[string]RequestCount
+}
+
+type operationKey struct{}
+
+// withOperation Labels requests sent with `ctx` as sent by `operation`.
+// Keeps the outer label if already labeled, so requests are counted to the method called by the user.
+func withOperation(ctx context.Context, operation string) context.Context {
+	if _, ok := ctx.Value(operationKey{}).(string); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, operationKey{}, operation)
+}
+
+// usageMeter Counts requests in total and per operation
+type usageMeter struct {
+	mu         sync.Mutex // guards all
+	total      RequestCount
+	operations map[string]RequestCount
+}
+
+func newUsageMeter() *usageMeter {
+	return &usageMeter{operations: make(map[string]RequestCount)}
+}
+
+func (u *usageMeter) record(ctx context.Context, write bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.total.add(write)
+	if operation, ok := ctx.Value(operationKey{}).(string); ok {
+		count := u.operations[operation]
+		count.add(write)
+		u.operations[operation] = count
+	}
+}
+
+func (u *usageMeter) usage() Usage {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	operations := make(map[string]RequestCount, len(u.operations))
+	for operation, count := range u.operations {
+		operations[operation] = count
+	}
+	return Usage{RequestCount: u.total, Operations: operations}
+}
+
+func (u *usageMeter) reset() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.total = RequestCount{}
+	u.operations = make(map[string]RequestCount)
+}
+
+// meterTransport Counts each request actually sent, after rate limiting
+type meterTransport struct {
+	base  http.RoundTripper
+	meter *usageMeter
+}
+
+func (t *meterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.meter.record(req.Context(), isWrite(req))
+	return t.base.RoundTrip(req)
+}
+
+// Usage Requests sent so far, counted as they go out to Google.
+// Reads and writes are told apart the same way as RateLimits.
+func (m *SheetManager) Usage() Usage {
+	return m.meter.usage()
+}
+
+// ResetUsage Starts counting requests from zero
+func (m *SheetManager) ResetUsage() {
+	m.meter.reset()
+}
@@ -0,0 +1,68 @@
+package gosheet
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/helloworldpark/gsheet-db-go/gsheettest"
+)
+
+func TestUsage(t *testing.T) {
+	manager, server := newTestManager(t, WithRetryPolicy(fastRetry))
+	defer server.Close()
+	db := newTestDatabase(t, manager)
+	table, err := db.CreateTable(TestStructMeme{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager.ResetUsage()
+	server.ResetRequests()
+	if err := table.UpsertIf(memes(3), true); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := table.SelectContext(context.Background(), -1); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := server.Requests()
+	var expected RequestCount
+	for _, req := range sent {
+		expected.add(req.Method != http.MethodGet)
+	}
+	usage := manager.Usage()
+	if usage.RequestCount != expected {
+		t.Fatalf("expected %+v, counted %+v", expected, usage.RequestCount)
+	}
+	upsert, selected := usage.Operations["Table.UpsertIf"], usage.Operations["Table.Select"]
+	if upsert.Writes == 0 || selected.Reads == 0 || selected.Writes != 0 {
+		t.Fatalf("unexpected operations %+v", usage.Operations)
+	}
+	if upsert.Total()+selected.Total() != int64(len(sent)) || len(usage.Operations) != 2 {
+		t.Fatalf("operations %+v do not add up to %d requests", usage.Operations, len(sent))
+	}
+
+	// retries are requests too
+	manager.ResetUsage()
+	server.InjectFault(1, gsheettest.Fault{Status: http.StatusServiceUnavailable, Method: http.MethodGet})
+	if _, _, err := table.Select(-1); err != nil {
+		t.Fatal(err)
+	}
+	if reads := manager.Usage().Operations["Table.Select"].Reads; reads != selected.Reads+1 {
+		t.Fatalf("expected %d reads with a retry, got %d", selected.Reads+1, reads)
+	}
+
+	manager.ResetUsage()
+	if usage := manager.Usage(); usage.Total() != 0 || len(usage.Operations) != 0 {
+		t.Fatalf("usage not reset: %+v", usage)
+	}
+}
+
+func TestWithOperation(t *testing.T) {
+	ctx := withOperation(context.Background(), "outer")
+	ctx = withOperation(ctx, "inner")
+	if operation := ctx.Value(operationKey{}); operation != "outer" {
+		t.Fatalf("expected the outer operation, got %v", operation)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/gob"
 	"fmt"
 	"reflect"
+	"strings"
 )
 
 func init() {
@@ -25,7 +26,9 @@ type cellRange struct {
 	startRow, endRow, startCol, endCol int64
 }
 
-func newCellRange(sheetName string, startRow, startCol, endRow, endCol int64) cellRange {
+// newCellRange Range of rows [startRow, endRow) and columns [startCol, endCol).
+// Returns an error if the range has no cell.
+func newCellRange(sheetName string, startRow, startCol, endRow, endCol int64) (cellRange, error) {
 	c := cellRange{
 		startRow:  startRow,
 		endRow:    endRow,
@@ -33,19 +36,18 @@ func newCellRange(sheetName string, startRow, startCol, endRow, endCol int64) ce
 		endCol:    endCol,
 		sheetName: sheetName,
 	}
-	if (c.startCol < 0 || c.startCol >= c.endCol) && (c.startRow < 0 || c.startRow >= c.endRow) {
-		err := fmt.Sprintf("Invalid cellRange: %+v", c)
-		panic(err)
+	if c.startCol < 0 || c.startCol >= c.endCol || c.startRow < 0 || c.startRow >= c.endRow {
+		return cellRange{}, fmt.Errorf("gosheet: invalid cell range %+v", c)
 	}
-	return c
+	return c, nil
 }
 
 func (c cellRange) String() string {
 
 	leftmost := base26(c.startCol + 1)
-	rightmost := base26(c.endCol + 1)
+	rightmost := base26(c.endCol)
 
-	ranges := fmt.Sprintf("%s%d:%s%d", leftmost, c.startRow+1, rightmost, c.endRow+1)
+	ranges := fmt.Sprintf("%s%d:%s%d", leftmost, c.startRow+1, rightmost, c.endRow)
 	ranges = fmt.Sprintf("%s!%s", c.sheetName, ranges)
 	return ranges
 }
@@ -64,14 +66,17 @@ func maximum64(x, y int64) int64 {
 	return x
 }
 
+// base26 Column letters of 1-based column number `x`
+// 1 <-> A, 26 <-> Z, 27 <-> AA
+// Empty if `x` is not positive.
 func base26(x int64) string {
-	if x < 0 {
-		panic(fmt.Sprintf("x should not be negative: %d", x))
+	var letters []byte
+	for x > 0 {
+		x--
+		letters = append([]byte{byte('A' + x%26)}, letters...)
+		x /= 26
 	}
-	if x > 26 {
-		panic(fmt.Sprintf("Unsupported number %d", x))
-	}
-	return string('@' + x)
+	return string(letters)
 }
 
 // reflection-related
@@ -92,6 +97,10 @@ func initPrimitiveKind() {
 	}
 	primitiveStringToKind[reflect.String.String()] = reflect.String
 	primitiveKindToString[reflect.String] = reflect.String.String()
+	// not primitives, but stored in a cell
+	primitiveStringToKind[timeTypeName] = reflect.Struct
+	primitiveStringToKind[durationTypeName] = reflect.Int64
+	primitiveStringToKind[jsonTypeName] = reflect.Interface
 }
 
 func isPrimitive(i interface{}) bool {
@@ -99,15 +108,24 @@ func isPrimitive(i interface{}) bool {
 	return ok
 }
 
-func nameOfStruct(i interface{}) string {
-	return reflect.TypeOf(i).Name()
+// nameOfStruct Name of the struct type of `i`, which is the name of its table
+func nameOfStruct(i interface{}) (string, error) {
+	t := reflect.TypeOf(i)
+	if t == nil || t.Kind() != reflect.Struct {
+		return "", fmt.Errorf("%w: %T is not a struct", ErrUnsupportedType, i)
+	}
+	return t.Name(), nil
 }
 
 type structField struct {
-	cname  string
-	ctype  string
-	ckind  reflect.Kind
-	cvalue interface{}
+	cname     string
+	ctype     string
+	ckind     reflect.Kind
+	cvalue    interface{}
+	unique    bool
+	omitempty bool
+	// index Index of the field for reflect.Value.FieldByIndex
+	index []int
 }
 
 func (f structField) isBool() bool {
@@ -122,35 +140,274 @@ func (f structField) isNumeric() bool {
 	return reflect.Int8 <= f.ckind && f.ckind <= reflect.Float64
 }
 
-func analyseStruct(structInstance interface{}) []structField {
+// tagKey Key of the struct tags read by gosheet
+const tagKey = "gsheet"
+
+// columnTag Options given by the struct tag of a field
+// `gsheet:"name"`: column name instead of the field name
+// `gsheet:"-"`: not a column
+// `gsheet:",unique"`: part of the unique constraint
+// `gsheet:",omitempty"`: zero values are written as empty cells
+// `gsheet:",json"`: stored as JSON, also structs which are flattened otherwise
+type columnTag struct {
+	name      string
+	named     bool // name given by the tag
+	skip      bool
+	unique    bool
+	omitempty bool
+	json      bool
+}
+
+func parseColumnTag(field reflect.StructField) (columnTag, error) {
+	tag, ok := field.Tag.Lookup(tagKey)
+	if !ok {
+		return columnTag{name: field.Name}, nil
+	}
+	// like encoding/json, "-," is a column named "-"
+	if tag == "-" {
+		return columnTag{skip: true}, nil
+	}
+	options := strings.Split(tag, ",")
+	parsed := columnTag{name: options[0], named: len(options[0]) > 0}
+	if !parsed.named {
+		parsed.name = field.Name
+	}
+	for _, option := range options[1:] {
+		switch option {
+		case "":
+		case "unique":
+			parsed.unique = true
+		case "omitempty":
+			parsed.omitempty = true
+		case "json":
+			parsed.json = true
+		default:
+			return columnTag{}, fmt.Errorf("gosheet: unknown option %q in the tag of field %s", option, field.Name)
+		}
+	}
+	return parsed, nil
+}
+
+func analyseStruct(structInstance interface{}) ([]structField, error) {
 	initPrimitiveKind()
 
 	reflected := reflect.TypeOf(structInstance)
-	if reflected.Kind() != reflect.Struct {
-		return nil
+	if reflected == nil || reflected.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: %T is not a struct", ErrUnsupportedType, structInstance)
 	}
 	reflectedValue := reflect.ValueOf(structInstance)
 
-	n := reflected.NumField()
-	fields := make([]structField, n)
+	fields, err := columnsOf(reflected)
+	if err != nil {
+		return nil, err
+	}
+	for i := range fields {
+		value, ok := fieldByIndex(reflectedValue, fields[i].index)
+		if !ok {
+			// under a nil embedded pointer
+			fields[i].cvalue = ""
+			continue
+		}
+		fields[i].cvalue = value.Interface()
+		if isNullable(fields[i].ctype) {
+			// nil is NULL, a blank cell
+			fields[i].cvalue = ""
+			if !value.IsNil() {
+				fields[i].cvalue = value.Elem().Interface()
+			}
+		} else if fields[i].omitempty && value.IsZero() {
+			fields[i].cvalue = ""
+		} else if fields[i].ctype == jsonTypeName {
+			text, err := marshalCell(fields[i].cvalue)
+			if err != nil {
+				return nil, fmt.Errorf("field %s of %s: %w", fields[i].cname, reflected.Name(), err)
+			}
+			fields[i].cvalue = text
+		}
+	}
+	return fields, nil
+}
+
+// columnCandidate A column before resolving promoted fields of the same name
+type columnCandidate struct {
+	structField
+	depth int  // depth of embedding
+	named bool // named by a tag
+}
 
-	for i := 0; i < n; i++ {
-		value := reflectedValue.Field(i)
-		valueType := reflectedValue.Type().Field(i)
-		valueKind := valueType.Type.Kind()
+// columnsOf Columns of struct type `t`, without values.
+// Nested structs are flattened into columns prefixed with the field name, e.g. Address.City.
+// Fields of embedded structs are promoted like encoding/json does: the shallowest one wins,
+// then the one named by a tag. Other promoted fields of the same name are ambiguous and dropped,
+// but fields of `t` itself, including nested ones, of the same name are errors.
+func columnsOf(t reflect.Type) ([]structField, error) {
+	var candidates []columnCandidate
+	if err := collectColumns(t, t, nil, "", 0, []reflect.Type{t}, columnTag{}, &candidates); err != nil {
+		return nil, err
+	}
 
-		typestring, ok := primitiveKindToString[valueKind]
+	byName := make(map[string][]int)
+	for i, c := range candidates {
+		byName[c.cname] = append(byName[c.cname], i)
+	}
+	fields := make([]structField, 0, len(candidates))
+	for i, c := range candidates {
+		dominant, err := dominantColumn(t, candidates, byName[c.cname])
+		if err != nil {
+			return nil, err
+		}
+		if dominant == i {
+			fields = append(fields, c.structField)
+		}
+	}
+	return fields, nil
+}
+
+// collectColumns Appends columns of struct type `t` at `index` of the root type to `candidates`.
+// `path` is the struct types from the root to `t`.
+func collectColumns(root, t reflect.Type, index []int, prefix string, depth int, path []reflect.Type, inherited columnTag, candidates *[]columnCandidate) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		exported := len(field.PkgPath) == 0
+		// unexported fields are not columns, but exported fields of unexported embedded structs are
+		if !exported && !(field.Anonymous && field.Type.Kind() == reflect.Struct) {
+			continue
+		}
+		tag, err := parseColumnTag(field)
+		if err != nil {
+			return err
+		}
+		if tag.skip {
+			continue
+		}
+		tag.unique = tag.unique || inherited.unique
+		tag.omitempty = tag.omitempty || inherited.omitempty
+		fieldIndex := append(append([]int(nil), index...), i)
+
+		if embedded := embeddedPointer(field, tag); embedded != nil {
+			// a struct embedding itself is not promoted again, as encoding/json does
+			if containsType(path, embedded) {
+				continue
+			}
+			// promoted like an embedded struct
+			if err := collectColumns(root, embedded, fieldIndex, prefix, depth+1, appendType(path, embedded), tag, candidates); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct && field.Type != timeType && !tag.json {
+			if field.Anonymous && !tag.named {
+				// promoted
+				err = collectColumns(root, field.Type, fieldIndex, prefix, depth+1, appendType(path, field.Type), tag, candidates)
+			} else {
+				err = collectColumns(root, field.Type, fieldIndex, prefix+tag.name+".", depth, appendType(path, field.Type), tag, candidates)
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		typestring, valueKind, ok := columnType(field.Type)
+		if tag.json {
+			typestring, valueKind, ok = jsonTypeName, reflect.Interface, true
+		}
 		if !ok {
-			fmt.Println("Not a struct: ", value)
-			return nil
+			return fmt.Errorf("%w: field %s of %s is %s", ErrUnsupportedType, prefix+field.Name, root.Name(), field.Type)
 		}
+		*candidates = append(*candidates, columnCandidate{
+			structField: structField{
+				cname:     prefix + tag.name,
+				ctype:     typestring,
+				ckind:     valueKind,
+				index:     fieldIndex,
+				unique:    tag.unique,
+				omitempty: tag.omitempty,
+			},
+			depth: depth,
+			named: tag.named,
+		})
+	}
+	return nil
+}
 
-		fields[i].ctype = typestring
-		fields[i].cname = valueType.Name
-		fields[i].ckind = valueKind
-		fields[i].cvalue = value.Interface()
+// embeddedPointer Struct type of an embedded *T promoted like encoding/json does, nil otherwise
+func embeddedPointer(field reflect.StructField, tag columnTag) reflect.Type {
+	if !field.Anonymous || tag.named || tag.json || field.Type.Kind() != reflect.Ptr {
+		return nil
+	}
+	elem := field.Type.Elem()
+	if elem.Kind() != reflect.Struct || elem == timeType {
+		return nil
+	}
+	return elem
+}
+
+func containsType(types []reflect.Type, t reflect.Type) bool {
+	for _, x := range types {
+		if x == t {
+			return true
+		}
+	}
+	return false
+}
+
+// appendType `types` followed by `t`, not sharing the array of `types`
+func appendType(types []reflect.Type, t reflect.Type) []reflect.Type {
+	return append(append([]reflect.Type(nil), types...), t)
+}
+
+// fieldByIndex reflect.Value.FieldByIndex, but false under a nil embedded pointer instead of panicking
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// dominantColumn Index of the column winning among `candidates` of the same name at `indices`.
+// -1 if promoted ones are ambiguous.
+func dominantColumn(root reflect.Type, candidates []columnCandidate, indices []int) (int, error) {
+	if len(indices) == 1 {
+		return indices[0], nil
+	}
+	depth := candidates[indices[0]].depth
+	for _, i := range indices {
+		if candidates[i].depth < depth {
+			depth = candidates[i].depth
+		}
+	}
+	shallowest, named := -1, -1
+	shallowCount, namedCount := 0, 0
+	for _, i := range indices {
+		if candidates[i].depth != depth {
+			continue
+		}
+		shallowest = i
+		shallowCount++
+		if candidates[i].named {
+			named = i
+			namedCount++
+		}
+	}
+	if shallowCount == 1 {
+		return shallowest, nil
+	}
+	if depth == 0 {
+		// not promoted, so a mistake of the struct
+		return -1, fmt.Errorf("gosheet: duplicate column %s in %s", candidates[indices[0]].cname, root.Name())
+	}
+	if namedCount == 1 {
+		return named, nil
 	}
-	return fields
+	// dropped as encoding/json does
+	return -1, nil
 }
 
 // https://gist.github.com/miguelmota/5bfa2b6ab88f439fe0da0bfb1faca763
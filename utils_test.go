@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 )
 
 type TestStruct struct {
@@ -31,10 +32,9 @@ func TestStructsReflection(t *testing.T) {
 		fmt.Println(structName, "is not Primitive")
 	}
 
-	analysed := analyseStruct(tt)
-	if len(analysed) == 0 {
-		fmt.Println("Failed to split ")
-		return
+	analysed, err := analyseStruct(tt)
+	if err != nil {
+		t.Fatal(err)
 	}
 
 	for i := 0; i < len(analysed); i++ {
@@ -42,8 +42,198 @@ func TestStructsReflection(t *testing.T) {
 	}
 }
 
+// Ranges end before endRow and endCol, not after
+func TestCellRangeString(t *testing.T) {
+	ranges := map[string]cellRange{
+		"Sheet!A4:B5":  {sheetName: "Sheet", startRow: 3, endRow: 5, startCol: 0, endCol: 2},
+		"Sheet!A1:Z3":  {sheetName: "Sheet", startRow: 0, endRow: 3, startCol: 0, endCol: 26},
+		"Sheet!C2:C2":  {sheetName: "Sheet", startRow: 1, endRow: 2, startCol: 2, endCol: 3},
+		"Sheet!A4:AA4": {sheetName: "Sheet", startRow: 3, endRow: 4, startCol: 0, endCol: 27},
+	}
+	for expected, c := range ranges {
+		if c.String() != expected {
+			t.Fatalf("expected %s, got %s", expected, c.String())
+		}
+	}
+}
+
 func TestBase26(t *testing.T) {
 	for i := 1; i <= 26*26*3+26*2+4; i++ {
 		fmt.Printf("[%04d] = %s\n--------------\n", i, base26(int64(i)))
 	}
 }
+
+func TestCellRange(t *testing.T) {
+	c, err := newCellRange("Sheet", 3, 0, 5, 27)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.String() != "Sheet!A4:AA5" {
+		t.Fatalf("range = %s", c.String())
+	}
+	// no column or no row is an error, not a panic
+	if _, err := newCellRange("Sheet", 3, 0, 5, 0); err == nil {
+		t.Fatal("expected an error for a range without columns")
+	}
+	if _, err := newCellRange("Sheet", 3, 0, 3, 2); err == nil {
+		t.Fatal("expected an error for a range without rows")
+	}
+	if base26(0) != "" {
+		t.Fatalf("base26(0) = %q", base26(0))
+	}
+}
+
+func TestSetSameUniqueColumns(t *testing.T) {
+	c := NewConstraint().SetUniqueColumns("Name1", "Name2")
+	c = c.SetUniqueColumns("Name1", "Name2")
+	if !reflect.DeepEqual(c.uniqueColumns, []string{"Name1", "Name2"}) {
+		t.Fatalf("unique columns = %v", c.uniqueColumns)
+	}
+}
+
+type TestStructTagged struct {
+	ID       int64  `gsheet:"id,unique"`
+	Name     string `gsheet:"display_name"`
+	Note     string `gsheet:",omitempty"`
+	Dash     string `gsheet:"-,"`
+	Ignored  []byte `gsheet:"-"`
+	internal string
+}
+
+func TestStructTags(t *testing.T) {
+	analysed, err := analyseStruct(TestStructTagged{ID: 1, Name: "a", Dash: "b", internal: "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	var values []interface{}
+	for _, f := range analysed {
+		names = append(names, f.cname)
+		values = append(values, f.cvalue)
+	}
+	if !reflect.DeepEqual(names, []string{"id", "display_name", "Note", "-"}) {
+		t.Fatalf("unexpected columns %v", names)
+	}
+	if !reflect.DeepEqual(values, []interface{}{int64(1), "a", "", "b"}) {
+		t.Fatalf("unexpected values %v", values)
+	}
+	if !analysed[0].unique || analysed[1].unique || !analysed[2].omitempty {
+		t.Fatalf("unexpected options %+v", analysed)
+	}
+
+	invalid := []interface{}{
+		struct {
+			A int `gsheet:",uniq"`
+		}{},
+		struct {
+			A int `gsheet:"same"`
+			B int `gsheet:"same"`
+		}{},
+		struct {
+			A chan int
+		}{},
+	}
+	for _, v := range invalid {
+		if _, err := analyseStruct(v); err == nil {
+			t.Fatalf("expected an error analysing %T", v)
+		}
+	}
+}
+
+type TestAddress struct {
+	City string
+	Zip  int
+}
+
+type testAudit struct {
+	CreatedBy string
+	Name      string
+}
+
+type TestBase struct {
+	ID int64 `gsheet:",unique"`
+}
+
+type TestStructNested struct {
+	TestBase
+	testAudit
+	Name    string
+	Home    TestAddress
+	Office  TestAddress `gsheet:"work,omitempty"`
+	Created time.Time
+}
+
+func TestNestedStruct(t *testing.T) {
+	created := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	analysed, err := analyseStruct(TestStructNested{
+		TestBase:  TestBase{ID: 7},
+		testAudit: testAudit{CreatedBy: "admin", Name: "shadowed"},
+		Name:      "kim",
+		Home:      TestAddress{City: "Seoul", Zip: 4524},
+		Created:   created,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	var values []interface{}
+	for _, f := range analysed {
+		names = append(names, f.cname)
+		values = append(values, f.cvalue)
+	}
+	// Name of testAudit is shadowed by the shallower Name
+	expectedNames := []string{"ID", "CreatedBy", "Name", "Home.City", "Home.Zip", "work.City", "work.Zip", "Created"}
+	expectedValues := []interface{}{int64(7), "admin", "kim", "Seoul", 4524, "", "", created}
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Fatalf("expected columns %v, got %v", expectedNames, names)
+	}
+	if !reflect.DeepEqual(values, expectedValues) {
+		t.Fatalf("expected values %v, got %v", expectedValues, values)
+	}
+	if !analysed[0].unique {
+		t.Fatal("promoted fields keep their tags")
+	}
+
+	type other struct {
+		City string
+	}
+	// fields which cannot be a column
+	if _, err := analyseStruct(struct {
+		Home TestAddress
+		Bad  func()
+	}{}); err == nil {
+		t.Fatal("expected an error analysing a func field")
+	}
+	// fields of the struct itself never shadow each other, tagged or not
+	if _, err := analyseStruct(struct {
+		A int64 `gsheet:"B"`
+		B int64
+	}{}); err == nil {
+		t.Fatal("expected an error analysing a tag naming another field")
+	}
+	// ambiguous fields at the same depth are dropped
+	analysed, err = analyseStruct(struct {
+		TestAddress
+		other
+	}{TestAddress{City: "Busan", Zip: 48058}, other{City: "Seoul"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(analysed) != 1 || analysed[0].cname != "Zip" {
+		t.Fatalf("unexpected columns %+v", analysed)
+	}
+	// the field named by a tag wins at the same depth
+	type tagged struct {
+		City string `gsheet:"City"`
+	}
+	analysed, err = analyseStruct(struct {
+		TestAddress
+		tagged
+	}{TestAddress{City: "Busan"}, tagged{City: "Seoul"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(analysed) != 2 || analysed[0].cname != "Zip" || analysed[1].cname != "City" || analysed[1].cvalue != "Seoul" {
+		t.Fatalf("unexpected columns %+v", analysed)
+	}
+}